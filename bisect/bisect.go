@@ -0,0 +1,103 @@
+package bisect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// OutputSource provides the output root that is claimed for an L2 block.
+type OutputSource interface {
+	OutputAtBlock(ctx context.Context, number uint64) (eth.Bytes32, error)
+}
+
+// Result is the outcome of a bisection: the last block the claims agree on, the pre-state,
+// and the first block they disagree on, the disputed post-state.
+type Result struct {
+	AgreedBlock    uint64      `json:"agreedBlock"`
+	AgreedOutput   eth.Bytes32 `json:"agreedOutput"`
+	DisputedBlock  uint64      `json:"disputedBlock"`
+	DisputedOutput eth.Bytes32 `json:"disputedOutput"`
+	HonestOutput   eth.Bytes32 `json:"honestOutput"`
+	Probes         int         `json:"probes"`
+}
+
+var (
+	ErrNoDispute     = errors.New("claims agree on the disputed block")
+	ErrAgreedDiffers = errors.New("claims disagree on the agreed block")
+)
+
+// Bisect searches for the first block after agreed, up to and including disputed, where the honest and
+// disputed output roots differ. Once the claims diverge they are assumed to stay diverged.
+func Bisect(ctx context.Context, logger log.Logger, honest OutputSource, disputed OutputSource, agreed uint64, disputedBlock uint64) (*Result, error) {
+	if disputedBlock <= agreed {
+		return nil, fmt.Errorf("disputed block %d must be after agreed block %d", disputedBlock, agreed)
+	}
+	probes := 0
+	probe := func(number uint64) (honestOut eth.Bytes32, disputedOut eth.Bytes32, err error) {
+		probes++
+		honestOut, err = honest.OutputAtBlock(ctx, number)
+		if err != nil {
+			return eth.Bytes32{}, eth.Bytes32{}, fmt.Errorf("honest output at block %d: %w", number, err)
+		}
+		disputedOut, err = disputed.OutputAtBlock(ctx, number)
+		if err != nil {
+			return eth.Bytes32{}, eth.Bytes32{}, fmt.Errorf("disputed output at block %d: %w", number, err)
+		}
+		logger.Info("Probed block", "number", number, "agree", honestOut == disputedOut, "honest", honestOut, "disputed", disputedOut)
+		return honestOut, disputedOut, nil
+	}
+
+	lo, hi := agreed, disputedBlock
+	loOut, loDisputed, err := probe(lo)
+	if err != nil {
+		return nil, err
+	}
+	if loOut != loDisputed {
+		return nil, fmt.Errorf("%w: block %d, honest %s, disputed %s", ErrAgreedDiffers, lo, loOut, loDisputed)
+	}
+	// The honest outputs are derived forward, so the search gallops forward from the agreed block
+	// and only derives up to about twice the distance to the first divergent block, not the whole range.
+	var hiOut, hiDisputed eth.Bytes32
+	for step := uint64(1); ; step *= 2 {
+		next := hi
+		if step < hi-lo {
+			next = lo + step
+		}
+		nextOut, nextDisputed, err := probe(next)
+		if err != nil {
+			return nil, err
+		}
+		if nextOut != nextDisputed {
+			hi, hiOut, hiDisputed = next, nextOut, nextDisputed
+			break
+		}
+		if next == hi {
+			return nil, fmt.Errorf("%w: block %d, output %s", ErrNoDispute, hi, nextOut)
+		}
+		lo, loOut = next, nextOut
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		midOut, midDisputed, err := probe(mid)
+		if err != nil {
+			return nil, err
+		}
+		if midOut == midDisputed {
+			lo, loOut = mid, midOut
+		} else {
+			hi, hiOut, hiDisputed = mid, midOut, midDisputed
+		}
+	}
+	return &Result{
+		AgreedBlock:    lo,
+		AgreedOutput:   loOut,
+		DisputedBlock:  hi,
+		DisputedOutput: hiDisputed,
+		HonestOutput:   hiOut,
+		Probes:         probes,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package bisect_test
+
+import (
+	"context"
+	"op-mordor/bisect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func outputs(from, to uint64, divergeAt uint64) bisect.OutputList {
+	list := make(bisect.OutputList)
+	for i := from; i <= to; i++ {
+		var out eth.Bytes32
+		out[0] = byte(i)
+		if i >= divergeAt {
+			out[1] = 0xff
+		}
+		list[i] = out
+	}
+	return list
+}
+
+// forwardSource records the highest block asked for, like a derivation that runs forward.
+type forwardSource struct {
+	bisect.OutputList
+	highest uint64
+}
+
+func (s *forwardSource) OutputAtBlock(ctx context.Context, number uint64) (eth.Bytes32, error) {
+	if number > s.highest {
+		s.highest = number
+	}
+	return s.OutputList.OutputAtBlock(ctx, number)
+}
+
+func TestBisect(t *testing.T) {
+	logger := log.New()
+	logger.SetHandler(log.DiscardHandler())
+	honest := outputs(100, 200, 1000)
+
+	for _, divergeAt := range []uint64{101, 137, 150, 199, 200} {
+		disputed := outputs(100, 200, divergeAt)
+		derived := &forwardSource{OutputList: honest}
+		res, err := bisect.Bisect(context.Background(), logger, derived, disputed, 100, 200)
+		require.NoError(t, err)
+		require.Equal(t, divergeAt-1, res.AgreedBlock)
+		require.Equal(t, honest[divergeAt-1], res.AgreedOutput)
+		require.Equal(t, divergeAt, res.DisputedBlock)
+		require.Equal(t, disputed[divergeAt], res.DisputedOutput)
+		require.Equal(t, honest[divergeAt], res.HonestOutput)
+		require.LessOrEqual(t, res.Probes, 1+7+6)
+		// the honest outputs are not derived much further than the divergence
+		require.LessOrEqual(t, derived.highest, 100+2*(divergeAt-100))
+	}
+
+	t.Run("no dispute", func(t *testing.T) {
+		_, err := bisect.Bisect(context.Background(), logger, honest, honest, 100, 200)
+		require.ErrorIs(t, err, bisect.ErrNoDispute)
+	})
+
+	t.Run("agreed differs", func(t *testing.T) {
+		_, err := bisect.Bisect(context.Background(), logger, honest, outputs(100, 200, 100), 100, 200)
+		require.ErrorIs(t, err, bisect.ErrAgreedDiffers)
+	})
+}
+
+func TestReadOutputList(t *testing.T) {
+	input := `{"number":10,"blockHash":"0x01","outputRoot":"0x0000000000000000000000000000000000000000000000000000000000000001"}
+{"number":"0xb","outputRoot":"0x0000000000000000000000000000000000000000000000000000000000000002"}
+`
+	list, err := bisect.ReadOutputList(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, eth.Bytes32{31: 1}, list[10])
+	require.Equal(t, eth.Bytes32{31: 2}, list[11])
+}
@@ -0,0 +1,121 @@
+package bisect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"op-mordor/derivation"
+	"op-mordor/l2"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DerivedOutputSource computes the honest output roots by deriving the L2 chain. Probes continue the same derivation,
+// and the output root of every derived block is kept as checkpoint, so earlier blocks do not need to be derived again.
+type DerivedOutputSource struct {
+	derivation *derivation.Derivation
+	outputs    *l2.OutputRootCache
+}
+
+var _ OutputSource = (*DerivedOutputSource)(nil)
+
+// NewDerivedOutputSource creates an output source for the derivation, the outputs must be the output root sink of
+// the L2 engine, and include the output root of the starting block.
+func NewDerivedOutputSource(d *derivation.Derivation, outputs *l2.OutputRootCache) *DerivedOutputSource {
+	return &DerivedOutputSource{derivation: d, outputs: outputs}
+}
+
+func (s *DerivedOutputSource) OutputAtBlock(ctx context.Context, number uint64) (eth.Bytes32, error) {
+	if out, ok := s.outputs.Get(number); ok {
+		return out.OutputRoot, nil
+	}
+	if err := s.derivation.RunUntil(number); err != nil {
+		return eth.Bytes32{}, err
+	}
+	out, ok := s.outputs.Get(number)
+	if !ok {
+		return eth.Bytes32{}, fmt.Errorf("no output root for derived block %d", number)
+	}
+	return out.OutputRoot, nil
+}
+
+// OutputList is a fixed list of claimed output roots, by block number.
+type OutputList map[uint64]eth.Bytes32
+
+var _ OutputSource = (OutputList)(nil)
+
+// ReadOutputList reads JSON lines of objects with a "number" and "outputRoot" field,
+// like the lines written by l2.OutputRootWriter.
+func ReadOutputList(r io.Reader) (OutputList, error) {
+	list := make(OutputList)
+	dec := json.NewDecoder(r)
+	for {
+		var entry struct {
+			Number     *jsonBlockNumber `json:"number"`
+			OutputRoot *eth.Bytes32     `json:"outputRoot"`
+		}
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return list, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding output root entry %d: %w", len(list), err)
+		}
+		if entry.Number == nil || entry.OutputRoot == nil {
+			return nil, fmt.Errorf("output root entry %d misses number or outputRoot", len(list))
+		}
+		list[uint64(*entry.Number)] = *entry.OutputRoot
+	}
+}
+
+func (l OutputList) OutputAtBlock(ctx context.Context, number uint64) (eth.Bytes32, error) {
+	out, ok := l[number]
+	if !ok {
+		return eth.Bytes32{}, fmt.Errorf("no claimed output root for block %d", number)
+	}
+	return out, nil
+}
+
+// jsonBlockNumber decodes a block number from a JSON number or a hex string.
+type jsonBlockNumber uint64
+
+func (n *jsonBlockNumber) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var v hexutil.Uint64
+		if err := v.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		*n = jsonBlockNumber(v)
+		return nil
+	}
+	var v uint64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*n = jsonBlockNumber(v)
+	return nil
+}
+
+// RollupRPCSource reads claimed output roots from the optimism_outputAtBlock method of a rollup node.
+type RollupRPCSource struct {
+	client *rpc.Client
+}
+
+var _ OutputSource = (*RollupRPCSource)(nil)
+
+func NewRollupRPCSource(client *rpc.Client) *RollupRPCSource {
+	return &RollupRPCSource{client: client}
+}
+
+func (s *RollupRPCSource) OutputAtBlock(ctx context.Context, number uint64) (eth.Bytes32, error) {
+	var out *eth.OutputResponse
+	if err := s.client.CallContext(ctx, &out, "optimism_outputAtBlock", hexutil.Uint64(number)); err != nil {
+		return eth.Bytes32{}, err
+	}
+	if out == nil {
+		return eth.Bytes32{}, fmt.Errorf("no output for block %d", number)
+	}
+	return out.OutputRoot, nil
+}
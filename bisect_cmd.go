@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"op-mordor/bisect"
+	"op-mordor/l2"
+	"op-mordor/program"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli"
+)
+
+func bisectCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 4, 4); err != nil {
+		return err
+	}
+	l1Hash, err := parseHash("l1 hash", c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	l2Hash, err := parseHash("l2 hash", c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	disputedBlock, err := strconv.ParseUint(c.Args().Get(2), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad disputed block number: %w", err)
+	}
+	disputed, err := openDisputedClaims(c.Args().Get(3), cfg.dialTimeout)
+	if err != nil {
+		return fmt.Errorf("opening disputed claims: %w", err)
+	}
+
+	ctx := context.Background()
+	conf, rollupCfg, err := cfg.loadConfigs()
+	if err != nil {
+		return err
+	}
+	l1Oracle, l2Oracle, err := cfg.setupOracles(logger)
+	if err != nil {
+		return fmt.Errorf("setting up oracles: %w", err)
+	}
+	opts, err := cfg.engineOptions(logger)
+	if err != nil {
+		return err
+	}
+	inputs := program.Inputs{L1Head: l1Hash, L2Head: l2Hash, ChainConfig: conf, RollupConfig: rollupCfg}
+	d, l2Engine, err := program.NewDerivation(ctx, logger, inputs, l1Oracle, l2Oracle, opts)
+	if err != nil {
+		return err
+	}
+	outputs := l2.NewOutputRootCache()
+	agreed, err := l2Engine.HeadOutputRoot()
+	if err != nil {
+		return fmt.Errorf("computing output root of agreed block: %w", err)
+	}
+	if err := outputs.Add(agreed); err != nil {
+		return fmt.Errorf("adding output root of agreed block: %w", err)
+	}
+	if opts.OutputRoots != nil {
+		// the --output-roots stream keeps receiving the output roots next to the bisection
+		l2Engine.SetOutputRootSink(l2.OutputRootSinks{opts.OutputRoots, outputs})
+	} else {
+		l2Engine.SetOutputRootSink(outputs)
+	}
+
+	honest := bisect.NewDerivedOutputSource(d, outputs)
+	res, err := bisect.Bisect(ctx, logger, honest, disputed, agreed.Number, disputedBlock)
+	if err != nil {
+		return fmt.Errorf("bisection: %w", err)
+	}
+	out, _ := json.MarshalIndent(res, "", "  ")
+	fmt.Println(string(out))
+	return nil
+}
+
+// openDisputedClaims opens a rollup node RPC if the input is a URL, and reads a claims file otherwise.
+func openDisputedClaims(input string, dialTimeout time.Duration) (bisect.OutputSource, error) {
+	if strings.Contains(input, "://") {
+		ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		defer cancel()
+		client, err := rpc.DialContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("dialing rollup rpc: %w", err)
+		}
+		return bisect.NewRollupRPCSource(client), nil
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("opening claims file: %w", err)
+	}
+	defer f.Close()
+	return bisect.ReadOutputList(f)
+}
@@ -17,13 +17,22 @@ import (
 type L2Access interface {
 	derive.Engine
 	L2OutputRoot() (eth.Bytes32, error)
+	// Halted returns a non-nil error if the engine failed in a way that must stop the derivation,
+	// even though the pipeline considers engine errors to be temporary.
+	Halted() error
 }
 
+// ErrL1Exhausted is returned when all L1 data is derived before reaching the requested L2 block.
+var ErrL1Exhausted = errors.New("L1 data exhausted")
+
 type Derivation struct {
 	logger    log.Logger
 	l1        derive.L1Fetcher
 	l2        L2Access
 	rollupCfg *rollup.Config
+
+	// pipeline is kept between runs, so the derivation can be continued
+	pipeline *derive.DerivationPipeline
 }
 
 func NewDerivation(logger log.Logger, rollupCfg *rollup.Config, l1 derive.L1Fetcher, l2 L2Access) *Derivation {
@@ -36,18 +45,51 @@ func NewDerivation(logger log.Logger, rollupCfg *rollup.Config, l1 derive.L1Fetc
 }
 
 func (d *Derivation) Run() (eth.Bytes32, error) {
-	err := d.runDerivation()
+	err := d.runDerivation(func() (bool, error) { return false, nil })
 	if err != nil {
 		return eth.Bytes32{}, err
 	}
 	return d.l2.L2OutputRoot()
 }
 
-func (d *Derivation) runDerivation() error {
-	pipeline := derive.NewDerivationPipeline(d.logger, d.rollupCfg, d.l1, d.l2, metrics.NoopMetrics)
-	pipeline.Reset()
+// RunUntil continues the derivation until the L2 head reaches the given block number.
+// ErrL1Exhausted is returned if the block cannot be derived from the available L1 data.
+func (d *Derivation) RunUntil(number uint64) error {
+	reached := func() (bool, error) {
+		head, err := d.l2.L2BlockRefByLabel(context.Background(), eth.Unsafe)
+		if err != nil {
+			return false, fmt.Errorf("l2 head err: %w", err)
+		}
+		return head.Number >= number, nil
+	}
+	if err := d.runDerivation(reached); err != nil {
+		return err
+	}
+	if ok, err := reached(); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%w: cannot derive L2 block %d", ErrL1Exhausted, number)
+	}
+	return nil
+}
+
+// runDerivation steps the pipeline until the L1 data is exhausted, or until done returns true.
+func (d *Derivation) runDerivation(done func() (bool, error)) error {
+	if d.pipeline == nil {
+		d.pipeline = derive.NewDerivationPipeline(d.logger, d.rollupCfg, d.l1, d.l2, metrics.NoopMetrics)
+		d.pipeline.Reset()
+	}
 	for {
-		if err := pipeline.Step(context.Background()); errors.Is(err, io.EOF) {
+		if ok, err := done(); err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+		err := d.pipeline.Step(context.Background())
+		if haltErr := d.l2.Halted(); haltErr != nil {
+			return fmt.Errorf("engine halted: %w", haltErr)
+		}
+		if errors.Is(err, io.EOF) {
 			return nil
 		} else if errors.Is(err, derive.ErrTemporary) {
 			d.logger.Warn("Temporary error in pipeline", "err", err)
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"op-mordor/l2"
+	"op-mordor/rpcpool"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+const envVarPrefix = "OP_"
+
+func prefixEnvVar(name string) string {
+	return envVarPrefix + name
+}
+
+const (
+	modeRPC   = "rpc"
+	modeStore = "store"
+)
+
+var (
+	/* Common flags, available on every command */
+	l1RpcFlag = cli.StringFlag{
+		Name:   "l1.rpc",
+		Usage:  "L1 JSON-RPC endpoint to load L1 pre-images from, or a comma-separated list of endpoints",
+		EnvVar: prefixEnvVar("L1_RPC"),
+	}
+	l2RpcFlag = cli.StringFlag{
+		Name:   "l2.rpc",
+		Usage:  "L2 JSON-RPC endpoint to load L2 pre-images from (debug namespace required), or a comma-separated list of endpoints",
+		EnvVar: prefixEnvVar("L2_RPC"),
+	}
+	rpcStrategyFlag = cli.StringFlag{
+		Name:   "rpc.strategy",
+		Usage:  "How calls are spread over several endpoints of a layer: 'failover' starts at the first endpoint, 'round-robin' rotates the endpoints. Calls move on to the next endpoint on errors",
+		Value:  string(rpcpool.Failover),
+		EnvVar: prefixEnvVar("RPC_STRATEGY"),
+	}
+	rpcParanoidFlag = cli.BoolFlag{
+		Name:   "rpc.paranoid",
+		Usage:  "Fetch every item from two endpoints of the layer, and fail if they disagree",
+		EnvVar: prefixEnvVar("RPC_PARANOID"),
+	}
+	storeFlag = cli.StringFlag{
+		Name:   "store",
+		Usage:  "Directory of the pre-image store, or leveldb://<dir> to use a LevelDB database",
+		Value:  "/tmp/mordor",
+		EnvVar: prefixEnvVar("STORE_PATH"),
+	}
+	preloadStoreFlag = cli.BoolFlag{
+		Name:   "store.preload",
+		Usage:  "Load all pre-images of the store into memory before the run, and flush new pre-images back when done",
+		EnvVar: prefixEnvVar("STORE_PRELOAD"),
+	}
+	storeCompressionFlag = cli.StringFlag{
+		Name:   "store.compression",
+		Usage:  "Compression of new pre-images in a disk store: none|snappy. Stores can be read regardless of their compression",
+		Value:  "none",
+		EnvVar: prefixEnvVar("STORE_COMPRESSION"),
+	}
+	dialTimeoutFlag = cli.DurationFlag{
+		Name:   "rpc.dial-timeout",
+		Usage:  "Timeout for dialing the JSON-RPC endpoints",
+		Value:  5 * time.Second,
+		EnvVar: prefixEnvVar("DIAL_TIMEOUT"),
+	}
+	logLevelFlag = cli.StringFlag{
+		Name:   "log.level",
+		Usage:  "Lowest log level that will be output: trace, debug, info, warn, error or crit",
+		Value:  "info",
+		EnvVar: prefixEnvVar("LOG_LEVEL"),
+	}
+	logFormatFlag = cli.StringFlag{
+		Name:   "log.format",
+		Usage:  "Log format: terminal, logfmt or json",
+		Value:  "terminal",
+		EnvVar: prefixEnvVar("LOG_FORMAT"),
+	}
+	chainConfigFlag = cli.StringFlag{
+		Name:   "l2.chain-config",
+		Usage:  "L2 chain config JSON file, defaults to the embedded Goerli config",
+		EnvVar: prefixEnvVar("CHAIN_CONFIG"),
+	}
+	rollupConfigFlag = cli.StringFlag{
+		Name:   "rollup.config",
+		Usage:  "Rollup config JSON file, defaults to the Goerli rollup config",
+		EnvVar: prefixEnvVar("ROLLUP_CONFIG"),
+	}
+	modeFlag = cli.StringFlag{
+		Name:   "mode",
+		Usage:  fmt.Sprintf("Oracle mode: %q loads missing pre-images from the RPCs into the store, %q only reads the store", modeRPC, modeStore),
+		Value:  modeRPC,
+		EnvVar: prefixEnvVar("MODE"),
+	}
+
+	/* Engine flags, enabling the debugging features of the L2 engine */
+	exportDirFlag = cli.StringFlag{
+		Name:   "export.dir",
+		Usage:  "Directory to write the receipts, failed transactions and state diff of every built block to",
+		EnvVar: prefixEnvVar("EXPORT_DIR"),
+	}
+	traceDirFlag = cli.StringFlag{
+		Name:   "trace.dir",
+		Usage:  "Directory to write a trace of every executed transaction to",
+		EnvVar: prefixEnvVar("TRACE_DIR"),
+	}
+	tracerFlag = cli.StringFlag{
+		Name:   "trace.tracer",
+		Usage:  "Tracer to trace transactions with: struct, call or prestate",
+		Value:  string(l2.CallTracer),
+		EnvVar: prefixEnvVar("TRACER"),
+	}
+	verifyReportFlag = cli.StringFlag{
+		Name:   "verify.report",
+		Usage:  "Verify every derived block against the L2 RPC, and write a mismatch report to this file",
+		EnvVar: prefixEnvVar("VERIFY_REPORT"),
+	}
+	outputRootsFlag = cli.StringFlag{
+		Name:   "output-roots.path",
+		Usage:  "File to stream the output roots of derived blocks to as JSON lines, - for stdout",
+		EnvVar: prefixEnvVar("OUTPUT_ROOTS"),
+	}
+	outputRootsIntervalFlag = cli.Uint64Flag{
+		Name:   "output-roots.interval",
+		Usage:  "Only stream the output roots of blocks with a number that is a multiple of the interval",
+		Value:  1,
+		EnvVar: prefixEnvVar("OUTPUT_ROOTS_INTERVAL"),
+	}
+
+	/* Command specific flags */
+	recordAccessLogFlag = cli.StringFlag{
+		Name:   "access-log.record",
+		Usage:  "File to log every pre-image request served by the oracles to, in order, as JSON lines. Cannot be combined with checkpoints",
+		EnvVar: prefixEnvVar("ACCESS_LOG_RECORD"),
+	}
+	witnessFlag = cli.StringFlag{
+		Name:   "witness",
+		Usage:  "Directory to export exactly the pre-images used by the run to, as a minimal self-contained store",
+		EnvVar: prefixEnvVar("WITNESS"),
+	}
+	bundleCompressionFlag = cli.StringFlag{
+		Name:  "compression",
+		Usage: "Compression of the bundle values: none|snappy",
+		Value: "none",
+	}
+	completeListsFlag = cli.BoolFlag{
+		Name:  "complete-lists",
+		Usage: "Require the transactions and receipts tries of every stored header to be stored completely",
+	}
+	kindFlag = cli.StringFlag{
+		Name:  "kind",
+		Usage: "Only list pre-images of this kind: headers|transactions|receipts|state|code|unknown",
+	}
+	gcRootsFlag = cli.StringFlag{
+		Name:  "roots",
+		Usage: "File with one \"<l1 head hash> <l2 block hash>\" pair per line, whose blocks, state and stored ancestors are kept",
+	}
+	gcAccessLogFlag = cli.StringSliceFlag{
+		Name:  "access-log",
+		Usage: "Recorded access log whose pre-images are kept, can be repeated",
+	}
+	gcBeforeL1Flag = cli.Uint64Flag{
+		Name:  "before.l1",
+		Usage: "Evict all L1 headers with a block number below this, and everything only they reach",
+	}
+	gcBeforeL2Flag = cli.Uint64Flag{
+		Name:  "before.l2",
+		Usage: "Evict all L2 headers with a block number below this, and everything only they reach. Checkpoints of runs whose L2 head is evicted are removed",
+	}
+	gcDryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the keys that would be removed, without removing them",
+	}
+	replayAccessLogFlag = cli.StringFlag{
+		Name:   "access-log",
+		Usage:  "Recorded access log that every pre-image request must match, in order",
+		EnvVar: prefixEnvVar("ACCESS_LOG"),
+	}
+	checkpointIntervalFlag = cli.Uint64Flag{
+		Name:   "checkpoint.interval",
+		Usage:  "Store a checkpoint every interval derived blocks, and resume from the latest checkpoint of the same L1 and L2 head. 0 disables checkpoints",
+		EnvVar: prefixEnvVar("CHECKPOINT_INTERVAL"),
+	}
+	outputProofFlag = cli.StringFlag{
+		Name:   "output-proof",
+		Usage:  "File to write the output root proof of the derived L2 head to",
+		EnvVar: prefixEnvVar("OUTPUT_PROOF"),
+	}
+	debugRpcAddrFlag = cli.StringFlag{
+		Name:   "debug-rpc.addr",
+		Usage:  "Address to serve the eth debug API over the derived L2 state on, after the run",
+		EnvVar: prefixEnvVar("DEBUG_RPC_ADDR"),
+	}
+	serveAddrFlag = cli.StringFlag{
+		Name:   "addr",
+		Usage:  "Address to serve the eth debug API over the derived L2 state on",
+		Value:  "127.0.0.1:8547",
+		EnvVar: prefixEnvVar("DEBUG_RPC_ADDR"),
+	}
+	preimageAddrFlag = cli.StringFlag{
+		Name:   "addr",
+		Usage:  "Address to serve the pre-images of the store on, as L1 and L2 RPC",
+		Value:  "127.0.0.1:8548",
+		EnvVar: prefixEnvVar("PREIMAGE_RPC_ADDR"),
+	}
+	verifyReportRequiredFlag = cli.StringFlag{
+		Name:   verifyReportFlag.Name,
+		Usage:  "File to write a report of the first block that does not match the L2 RPC to",
+		Value:  "mismatch.json",
+		EnvVar: verifyReportFlag.EnvVar,
+	}
+)
+
+var commonFlags = []cli.Flag{
+	l1RpcFlag,
+	l2RpcFlag,
+	rpcStrategyFlag,
+	rpcParanoidFlag,
+	storeFlag,
+	storeCompressionFlag,
+	preloadStoreFlag,
+	dialTimeoutFlag,
+	logLevelFlag,
+	logFormatFlag,
+	chainConfigFlag,
+	rollupConfigFlag,
+}
+
+var engineFlags = []cli.Flag{
+	exportDirFlag,
+	traceDirFlag,
+	tracerFlag,
+	outputRootsFlag,
+	outputRootsIntervalFlag,
+}
+
+func withFlags(groups ...[]cli.Flag) []cli.Flag {
+	var out []cli.Flag
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
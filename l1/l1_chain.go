@@ -7,6 +7,7 @@ import (
 
 	"github.com/ethereum-optimism/optimism/op-node/eth"
 	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -51,6 +52,10 @@ func (l *OracleBackedL1Chain) L1BlockRefByNumber(ctx context.Context, number uin
 	if ok {
 		return l.L1BlockRefByHash(ctx, hash)
 	}
+	if number > l.head.NumberU64() {
+		// the pipeline stops traversing L1 at the head
+		return eth.L1BlockRef{}, ethereum.NotFound
+	}
 	block := l.head
 	for block.NumberU64() > number {
 		parent, err := l.InfoByHash(ctx, block.ParentHash())
@@ -12,17 +12,34 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// Client is the part of the L1 JSON-RPC API that the LoadingL1Oracle uses, as implemented by ethclient.Client.
+type Client interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+var _ Client = (*ethclient.Client)(nil)
+
 // LoadingL1Oracle is an implementation of oracle.L1Oracle that loads content from another node via JSON-RPC API.
+// Content is read from a store.Source first, and only loaded if it is not there.
 // Loaded data is written to a store.Store to make the pre-image data available for later execution without needing another node.
 type LoadingL1Oracle struct {
 	logger log.Logger
-	client *ethclient.Client
+	client Client
 	store  store.Store
+	source store.Source
 }
 
 var _ oracle.L1Oracle = (*LoadingL1Oracle)(nil)
 
 func (l *LoadingL1Oracle) FetchL1Header(ctx context.Context, blockHash common.Hash) (*types.Header, error) {
+	sh, err := l.source.ReadHeader(blockHash)
+	if err == nil {
+		return sh, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring header: %w", err)
+	}
 	h, err := l.client.HeaderByHash(ctx, blockHash)
 	if err != nil {
 		return nil, err
@@ -36,6 +53,16 @@ func (l *LoadingL1Oracle) FetchL1Header(ctx context.Context, blockHash common.Ha
 }
 
 func (l *LoadingL1Oracle) FetchL1BlockTransactions(ctx context.Context, blockHash common.Hash) (types.Transactions, error) {
+	h, err := l.FetchL1Header(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	stxs, err := l.source.ReadTransactions(h.TxHash)
+	if err == nil {
+		return stxs, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring transactions: %w", err)
+	}
 	bl, err := l.client.BlockByHash(ctx, blockHash)
 	if err != nil {
 		return nil, err
@@ -50,10 +77,23 @@ func (l *LoadingL1Oracle) FetchL1BlockTransactions(ctx context.Context, blockHas
 }
 
 func (l *LoadingL1Oracle) FetchL1BlockReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	h, err := l.FetchL1Header(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
 	transactions, err := l.FetchL1BlockTransactions(ctx, blockHash)
 	if err != nil {
 		return nil, err
 	}
+	sreceipts, err := l.source.ReadReceipts(h.ReceiptHash)
+	if err == nil {
+		if err := store.DeriveReceiptFields(sreceipts, h, transactions); err != nil {
+			return nil, fmt.Errorf("restoring receipts: %w", err)
+		}
+		return sreceipts, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring receipts: %w", err)
+	}
 	var receipts []*types.Receipt
 	for _, transaction := range transactions {
 		receipt, err := l.client.TransactionReceipt(ctx, transaction.Hash())
@@ -70,12 +110,12 @@ func (l *LoadingL1Oracle) FetchL1BlockReceipts(ctx context.Context, blockHash co
 	return receipts, nil
 }
 
-var _ oracle.L1Oracle = (*LoadingL1Oracle)(nil)
-
-func NewLoadingL1Chain(logger log.Logger, client *ethclient.Client, store store.Store) oracle.L1Oracle {
+// NewLoadingL1Chain creates an L1 oracle that reads the source first, and loads missing content from the client into the store.
+func NewLoadingL1Chain(logger log.Logger, client Client, store store.Store, source store.Source) oracle.L1Oracle {
 	return &LoadingL1Oracle{
 		logger: logger,
 		client: client,
 		store:  store,
+		source: source,
 	}
 }
@@ -0,0 +1,43 @@
+package l1
+
+import (
+	"context"
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingL1OracleReadsStoreFirst(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	block, receipts := testutils.RandomBlock(rng, 4)
+
+	s := store.NewMemoryStore()
+	require.NoError(t, s.StoreHeader(block.Hash(), block.Header()))
+	require.NoError(t, s.StoreTransactions(block.TxHash(), block.Transactions()))
+	require.NoError(t, s.StoreReceipts(receipts))
+
+	// without a client, any fallback to RPC fails the test
+	o := NewLoadingL1Chain(log.New(), nil, s, s)
+	ctx := context.Background()
+
+	header, err := o.FetchL1Header(ctx, block.Hash())
+	require.NoError(t, err)
+	require.Equal(t, block.Hash(), header.Hash())
+
+	txs, err := o.FetchL1BlockTransactions(ctx, block.Hash())
+	require.NoError(t, err)
+	require.Len(t, txs, len(block.Transactions()))
+
+	got, err := o.FetchL1BlockReceipts(ctx, block.Hash())
+	require.NoError(t, err)
+	require.Len(t, got, len(receipts))
+	for i, r := range got {
+		require.Equal(t, receipts[i].TxHash, r.TxHash)
+		require.Equal(t, receipts[i].GasUsed, r.GasUsed)
+		require.Equal(t, receipts[i].Logs, r.Logs)
+	}
+}
@@ -0,0 +1,62 @@
+package l1
+
+import (
+	"context"
+	"fmt"
+	"op-mordor/oracle"
+	"op-mordor/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// StoreL1Oracle is an implementation of oracle.L1Oracle that only reads pre-images from a store.Source.
+// Content that is not stored results in a store.NoDataError.
+type StoreL1Oracle struct {
+	source store.Source
+}
+
+var _ oracle.L1Oracle = (*StoreL1Oracle)(nil)
+
+func NewStoreL1Oracle(source store.Source) *StoreL1Oracle {
+	return &StoreL1Oracle{source: source}
+}
+
+func (s *StoreL1Oracle) FetchL1Header(ctx context.Context, blockHash common.Hash) (*types.Header, error) {
+	h, err := s.source.ReadHeader(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	return h, nil
+}
+
+func (s *StoreL1Oracle) FetchL1BlockTransactions(ctx context.Context, blockHash common.Hash) (types.Transactions, error) {
+	h, err := s.FetchL1Header(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.source.ReadTransactions(h.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading transactions: %w", err)
+	}
+	return txs, nil
+}
+
+func (s *StoreL1Oracle) FetchL1BlockReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	h, err := s.FetchL1Header(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.source.ReadTransactions(h.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading transactions: %w", err)
+	}
+	receipts, err := s.source.ReadReceipts(h.ReceiptHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading receipts: %w", err)
+	}
+	if err := store.DeriveReceiptFields(receipts, h, txs); err != nil {
+		return nil, fmt.Errorf("restoring receipts: %w", err)
+	}
+	return receipts, nil
+}
@@ -0,0 +1,33 @@
+package l1
+
+import (
+	"context"
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreL1Oracle(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	block, receipts := testutils.RandomBlock(rng, 4)
+	s := store.NewMemoryStore()
+	o := NewStoreL1Oracle(s)
+	ctx := context.Background()
+
+	_, err := o.FetchL1Header(ctx, block.Hash())
+	require.True(t, store.IsNoDataError(err))
+
+	require.NoError(t, s.StoreHeader(block.Hash(), block.Header()))
+	require.NoError(t, s.StoreTransactions(block.TxHash(), block.Transactions()))
+	_, err = o.FetchL1BlockReceipts(ctx, block.Hash())
+	require.True(t, store.IsNoDataError(err))
+
+	require.NoError(t, s.StoreReceipts(receipts))
+	got, err := o.FetchL1BlockReceipts(ctx, block.Hash())
+	require.NoError(t, err)
+	require.Len(t, got, len(receipts))
+	require.Equal(t, receipts[0].Logs, got[0].Logs)
+}
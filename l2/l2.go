@@ -2,6 +2,7 @@ package l2
 
 import (
 	"context"
+	"fmt"
 	"op-mordor/derivation"
 	"op-mordor/oracle"
 
@@ -35,3 +36,23 @@ func NewL2Engine(ctx context.Context, log log.Logger, cfg *params.ChainConfig, l
 		OracleBackedL2Chain: l2Chain,
 	}, nil
 }
+
+// Transition inserts a single payload on top of the current head, executing it if it was not built by this engine,
+// and returns the output root of the resulting head.
+func (e *L2Engine) Transition(ctx context.Context, payload *eth.ExecutionPayload) (eth.Bytes32, error) {
+	if head := e.currentBlock(); payload.ParentHash != head.Hash() {
+		return eth.Bytes32{}, fmt.Errorf("payload parent %s does not match engine head %s", payload.ParentHash, head.Hash())
+	}
+	status, err := e.NewPayload(ctx, payload)
+	if err != nil {
+		return eth.Bytes32{}, fmt.Errorf("inserting payload %s: %w", payload.ID(), err)
+	}
+	if status.Status != eth.ExecutionValid {
+		reason := string(status.Status)
+		if status.ValidationError != nil {
+			reason += ": " + *status.ValidationError
+		}
+		return eth.Bytes32{}, fmt.Errorf("payload %s was not accepted: %s", payload.ID(), reason)
+	}
+	return e.L2OutputRoot()
+}
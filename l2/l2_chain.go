@@ -2,7 +2,7 @@ package l2
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"op-mordor/oracle"
 
 	"github.com/ethereum-optimism/optimism/op-node/eth"
@@ -22,6 +22,9 @@ type OracleBackedL2Chain struct {
 
 	head   eth.BlockInfo
 	blocks map[common.Hash]*types.Block
+
+	// err is the first error of the oracle, kept since the lookups return nil instead, like the geth chain context
+	err error
 }
 
 func NewOracleBackedL2Chain(
@@ -40,7 +43,14 @@ func NewOracleBackedL2Chain(
 }
 
 func (l *OracleBackedL2Chain) handleErr(err error) {
-	log.Fatalf("OracleBackedL2Chain fatal error: %v", err)
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// Err returns the first error of the oracle, or nil if all blocks could be loaded.
+func (l *OracleBackedL2Chain) Err() error {
+	return l.err
 }
 
 func (l *OracleBackedL2Chain) SetHead(head eth.BlockInfo) {
@@ -51,43 +61,83 @@ func (l *OracleBackedL2Chain) currentBlock() eth.BlockInfo {
 	return l.head
 }
 
+// getBlockByHash returns nil if the block could not be loaded, the error is kept for Err.
 func (l *OracleBackedL2Chain) getBlockByHash(hash common.Hash) *types.Block {
+	block, err := l.loadBlock(hash)
+	if err != nil {
+		l.handleErr(err)
+		return nil
+	}
+	return block
+}
+
+func (l *OracleBackedL2Chain) loadBlock(hash common.Hash) (*types.Block, error) {
 	block, ok := l.blocks[hash]
 	if ok {
-		return block
+		return block, nil
 	}
 
 	block, err := l.oracle.FetchL2Block(l.ctx, hash)
 	if err != nil {
-		l.handleErr(err)
-		return nil
+		return nil, fmt.Errorf("failed to load L2 block %s: %w", hash, err)
 	}
 	l.blocks[hash] = block
 
-	return block
+	return block, nil
 }
 
 // getBlockByNumber iterates back from the head until the block with number u is
 // found. It uses getBlockByHash so all blocks retrieved this way are locally
-// cached.
+// cached. It returns nil if a block could not be loaded.
 func (l *OracleBackedL2Chain) getBlockByNumber(u uint64) *types.Block {
 	block := l.getBlockByHash(l.head.Hash())
-	for block.NumberU64() > u {
+	for block != nil && block.NumberU64() > u {
 		block = l.getBlockByHash(block.ParentHash())
 	}
 	return block
 }
 
+// knownBlock returns the block with the given hash only if it was already built or loaded, without using the oracle.
+func (l *OracleBackedL2Chain) knownBlock(hash common.Hash) (*types.Block, bool) {
+	block, ok := l.blocks[hash]
+	return block, ok
+}
+
+// knownBlockByNumber is like getBlockByNumber, but only traverses blocks that were already built or loaded.
+func (l *OracleBackedL2Chain) knownBlockByNumber(u uint64) (*types.Block, bool) {
+	block, ok := l.knownBlock(l.head.Hash())
+	for ok && block.NumberU64() > u {
+		block, ok = l.knownBlock(block.ParentHash())
+	}
+	if !ok || block.NumberU64() != u {
+		return nil, false
+	}
+	return block, true
+}
+
 func (l *OracleBackedL2Chain) getBlockInfoByHash(hash common.Hash) eth.BlockInfo {
-	return eth.HeaderBlockInfo(l.getHeaderByHash(hash))
+	header := l.getHeaderByHash(hash)
+	if header == nil {
+		return nil
+	}
+	return eth.HeaderBlockInfo(header)
 }
 
 func (l *OracleBackedL2Chain) getHeaderByHash(hash common.Hash) *types.Header {
-	return l.getBlockByHash(hash).Header()
+	block := l.getBlockByHash(hash)
+	if block == nil {
+		return nil
+	}
+	return block.Header()
 }
 
+// getBlockHashByNumber returns the zero hash if a block could not be loaded.
 func (l *OracleBackedL2Chain) getBlockHashByNumber(u uint64) common.Hash {
-	return l.getBlockByNumber(u).Hash()
+	block := l.getBlockByNumber(u)
+	if block == nil {
+		return common.Hash{}
+	}
+	return block.Hash()
 }
 
 // used by geth chain context
@@ -96,12 +146,20 @@ func (l *OracleBackedL2Chain) getHeader(hash common.Hash, _ uint64) *types.Heade
 }
 
 func (l *OracleBackedL2Chain) PayloadByHash(_ context.Context, hash common.Hash) (*eth.ExecutionPayload, error) {
-	block := l.getBlockByHash(hash)
+	block, err := l.loadBlock(hash)
+	if err != nil {
+		l.handleErr(err)
+		return nil, err
+	}
 	return eth.BlockAsPayload(block)
 }
 
 func (l *OracleBackedL2Chain) PayloadByNumber(ctx context.Context, u uint64) (*eth.ExecutionPayload, error) {
-	return l.PayloadByHash(ctx, l.getBlockHashByNumber(u))
+	block := l.getBlockByNumber(u)
+	if block == nil {
+		return nil, l.err
+	}
+	return eth.BlockAsPayload(block)
 }
 
 func (l *OracleBackedL2Chain) L2BlockRefByLabel(ctx context.Context, label eth.BlockLabel) (eth.L2BlockRef, error) {
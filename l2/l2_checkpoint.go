@@ -0,0 +1,116 @@
+package l2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"op-mordor/oracle"
+	"op-mordor/store"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Checkpoint is the progress of a derivation run. The derivation pipeline resets to the L1 origin of the L2 head,
+// so a run can be continued from the L2 head of the checkpoint instead of the original L2 start.
+type Checkpoint struct {
+	L1Head  common.Hash    `json:"l1Head"`
+	L2Start common.Hash    `json:"l2Start"`
+	L2Head  eth.L2BlockRef `json:"l2Head"`
+}
+
+// CheckpointStore is a store that can hold checkpoints, and serve the blocks and state of the checkpoints.
+type CheckpointStore interface {
+	store.Store
+	store.Source
+	store.CheckpointStore
+}
+
+// Checkpointer persists the blocks inserted into the engine, and every interval blocks the state written by the engine
+// and a checkpoint of the run, so a crashed run can resume from the latest checkpoint.
+type Checkpointer struct {
+	store    CheckpointStore
+	id       common.Hash
+	l1Head   common.Hash
+	l2Start  common.Hash
+	interval uint64
+}
+
+// NewCheckpointer creates a checkpointer for the run that derives from the given L1 head on top of the L2 start.
+func NewCheckpointer(s CheckpointStore, l1Head common.Hash, l2Start common.Hash, interval uint64) *Checkpointer {
+	return &Checkpointer{
+		store:    s,
+		id:       crypto.Keccak256Hash([]byte("checkpoint"), l1Head[:], l2Start[:]),
+		l1Head:   l1Head,
+		l2Start:  l2Start,
+		interval: interval,
+	}
+}
+
+// Latest returns the latest checkpoint of the run, or nil if there is none.
+func (c *Checkpointer) Latest() (*Checkpoint, error) {
+	data, err := c.store.ReadCheckpoint(c.id)
+	if store.IsNoDataError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// Oracle wraps the L2 oracle to load the blocks and state of earlier checkpoints from the store first.
+// Blocks built by the engine may not be known to the oracle, if these differ from the canonical chain.
+func (c *Checkpointer) Oracle(l2Oracle oracle.L2Oracle) oracle.L2Oracle {
+	return &checkpointOracle{L2Oracle: l2Oracle, source: store.BlockSource{Source: c.store}}
+}
+
+// include returns whether the state and a checkpoint are written after inserting the block with the given number.
+func (c *Checkpointer) include(number uint64) bool {
+	return c.interval > 0 && number%c.interval == 0
+}
+
+func (c *Checkpointer) storeBlock(block *types.Block) error {
+	return store.BlockStore{Store: c.store}.StoreBlock(block)
+}
+
+func (c *Checkpointer) writeCheckpoint(db *OracleBackedDB, head eth.L2BlockRef) error {
+	if err := db.FlushWrites(c.store); err != nil {
+		return fmt.Errorf("storing state: %w", err)
+	}
+	data, err := json.Marshal(&Checkpoint{L1Head: c.l1Head, L2Start: c.l2Start, L2Head: head})
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	return c.store.StoreCheckpoint(c.id, data)
+}
+
+type checkpointOracle struct {
+	oracle.L2Oracle
+	source store.BlockSource
+}
+
+func (o *checkpointOracle) FetchL2MPTNode(ctx context.Context, nodeHash common.Hash) ([]byte, error) {
+	node, err := o.source.ReadNode(nodeHash)
+	if err == nil {
+		return node, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring node: %w", err)
+	}
+	return o.L2Oracle.FetchL2MPTNode(ctx, nodeHash)
+}
+
+func (o *checkpointOracle) FetchL2Block(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	block, err := o.source.ReadBlock(blockHash)
+	if err == nil {
+		return block, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring block: %w", err)
+	}
+	return o.L2Oracle.FetchL2Block(ctx, blockHash)
+}
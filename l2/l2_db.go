@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"op-mordor/oracle"
+	"op-mordor/store"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -24,6 +27,12 @@ type OracleBackedDB struct {
 	db *memorydb.Database
 
 	oracle oracle.L2StateOracle
+
+	// keys written since the last flush, only tracked after TrackWrites is called
+	written map[string]struct{}
+
+	// err is the first error of the in-memory db, kept since the trie database does not pass errors on
+	err error
 }
 
 func NewOracleBackedDB(oracle oracle.L2StateOracle) *OracleBackedDB {
@@ -38,30 +47,80 @@ func (p *OracleBackedDB) Has(key []byte) (bool, error) {
 }
 
 func (p *OracleBackedDB) Get(key []byte) ([]byte, error) {
-	if len(key) != 32 {
-		return nil, fmt.Errorf("can only read 32-byte key values, pre-images must be identified by hash")
-	}
 	v, err := p.db.Get(key)
 	if err == nil {
 		return v, nil
 	}
 	if err.Error() == "not found" {
+		// other values, like trie key preimages, may be written to and read from the in-memory db, but not the oracle
+		if len(key) != 32 {
+			return nil, fmt.Errorf("can only read 32-byte key values, pre-images must be identified by hash")
+		}
 		v, err := p.oracle.FetchL2MPTNode(context.TODO(), *(*[32]byte)(key))
 		if err != nil {
 			return nil, err
 		}
 		if err := p.db.Put(key, v); err != nil {
-			panic(fmt.Errorf("failed to put value into mem db: %w", err))
+			err = fmt.Errorf("failed to put value into mem db: %w", err)
+			if p.err == nil {
+				p.err = err
+			}
+			return nil, err
 		}
 		return v, nil
 	}
 	return nil, err
 }
 
+// Err returns the first error of the in-memory db, or nil.
+func (p *OracleBackedDB) Err() error {
+	return p.err
+}
+
 func (p *OracleBackedDB) Put(key []byte, value []byte) error {
+	p.markWritten(key)
 	return p.db.Put(key, value)
 }
 
+// TrackWrites starts tracking the keys that are written, as opposed to loaded from the oracle, for FlushWrites.
+func (p *OracleBackedDB) TrackWrites() {
+	if p.written == nil {
+		p.written = make(map[string]struct{})
+	}
+}
+
+func (p *OracleBackedDB) markWritten(key []byte) {
+	if p.written != nil {
+		p.written[string(key)] = struct{}{}
+	}
+}
+
+// FlushWrites stores the trie nodes and contract code written since the last flush,
+// so a later run can load the state built by this run from the store.
+// Other written values, like trie key preimages, are not pre-images of the oracle and are not stored.
+func (p *OracleBackedDB) FlushWrites(s store.Store) error {
+	for key := range p.written {
+		hash := []byte(key)
+		if ok, codeHash := rawdb.IsCodeKey(hash); ok {
+			hash = codeHash
+		} else if len(hash) != common.HashLength {
+			delete(p.written, key)
+			continue
+		}
+		v, err := p.db.Get([]byte(key))
+		if err != nil {
+			// written to a batch that was never committed
+			delete(p.written, key)
+			continue
+		}
+		if err := s.StoreNode(common.BytesToHash(hash), v); err != nil {
+			return fmt.Errorf("storing %x: %w", hash, err)
+		}
+		delete(p.written, key)
+	}
+	return nil
+}
+
 func (p OracleBackedDB) Delete(key []byte) error {
 	// we never delete pre-images
 	return nil
@@ -71,12 +130,23 @@ func (p OracleBackedDB) Stat(property string) (string, error) {
 	panic("not supported")
 }
 
-func (p OracleBackedDB) NewBatch() ethdb.Batch {
-	return p.db.NewBatch()
+func (p *OracleBackedDB) NewBatch() ethdb.Batch {
+	return &trackingBatch{Batch: p.db.NewBatch(), db: p}
+}
+
+func (p *OracleBackedDB) NewBatchWithSize(size int) ethdb.Batch {
+	return &trackingBatch{Batch: p.db.NewBatchWithSize(size), db: p}
+}
+
+// trackingBatch marks the keys put into the batch as written.
+type trackingBatch struct {
+	ethdb.Batch
+	db *OracleBackedDB
 }
 
-func (p OracleBackedDB) NewBatchWithSize(size int) ethdb.Batch {
-	return p.db.NewBatchWithSize(size)
+func (b *trackingBatch) Put(key []byte, value []byte) error {
+	b.db.markWritten(key)
+	return b.Batch.Put(key, value)
 }
 
 func (p OracleBackedDB) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
@@ -0,0 +1,336 @@
+package l2
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DebugAPI serves a read-only subset of the eth_ JSON-RPC namespace over the blocks and state
+// that the engine has built or loaded, so the computed state can be compared against a reference node.
+type DebugAPI struct {
+	// mu serializes requests, the chain and engine are not safe for concurrent use.
+	mu     sync.Mutex
+	engine *L2Engine
+}
+
+func NewDebugAPI(engine *L2Engine) *DebugAPI {
+	return &DebugAPI{engine: engine}
+}
+
+// NewDebugRPCServer creates a JSON-RPC server with the DebugAPI registered in the "eth" namespace.
+// The engine must not be used for derivation while the server is serving requests.
+func NewDebugRPCServer(engine *L2Engine) (*rpc.Server, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", NewDebugAPI(engine)); err != nil {
+		return nil, fmt.Errorf("registering debug api: %w", err)
+	}
+	return srv, nil
+}
+
+var errUnknownBlock = errors.New("unknown block")
+
+func (api *DebugAPI) blockByNumber(num rpc.BlockNumber) (*types.Block, bool) {
+	chain := api.engine.OracleBackedL2Chain
+	switch num {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return chain.knownBlock(chain.currentBlock().Hash())
+	case rpc.SafeBlockNumber:
+		return chain.knownBlock(api.engine.safe)
+	case rpc.FinalizedBlockNumber:
+		return chain.knownBlock(api.engine.finalized.Hash)
+	default:
+		return chain.knownBlockByNumber(uint64(num))
+	}
+}
+
+func (api *DebugAPI) blockByNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, ok := api.engine.OracleBackedL2Chain.knownBlock(hash)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownBlock, hash)
+		}
+		return block, nil
+	}
+	if num, ok := blockNrOrHash.Number(); ok {
+		block, ok := api.blockByNumber(num)
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", errUnknownBlock, num)
+		}
+		return block, nil
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+func (api *DebugAPI) stateAndHeader(blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	block, err := api.blockByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(api.engine.l2Database), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open L2 state db at block %s: %w", block.Hash(), err)
+	}
+	return statedb, block.Header(), nil
+}
+
+func (api *DebugAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	statedb, _, err := api.stateAndHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(statedb.GetBalance(address)), statedb.Error()
+}
+
+func (api *DebugAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	statedb, _, err := api.stateAndHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(address), statedb.Error()
+}
+
+func (api *DebugAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	statedb, _, err := api.stateAndHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := decodeStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	v := statedb.GetState(address, slot)
+	return v[:], statedb.Error()
+}
+
+// AccountResult is the eth_getProof response, identical to the go-ethereum format.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+func (api *DebugAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	statedb, _, err := api.stateAndHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+
+	storageTrie := statedb.StorageTrie(address)
+	storageHash := types.EmptyRootHash
+	codeHash := statedb.GetCodeHash(address)
+	if storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	} else {
+		// the account does not exist, so the code hash is the hash of empty code
+		codeHash = crypto.Keccak256Hash(nil)
+	}
+
+	storageProof := make([]StorageResult, len(storageKeys))
+	for i, hexKey := range storageKeys {
+		key, err := decodeStorageKey(hexKey)
+		if err != nil {
+			return nil, err
+		}
+		if storageTrie == nil {
+			storageProof[i] = StorageResult{hexKey, &hexutil.Big{}, []string{}}
+			continue
+		}
+		proof, err := statedb.GetStorageProof(address, key)
+		if err != nil {
+			return nil, fmt.Errorf("storage proof of key %s: %w", hexKey, err)
+		}
+		storageProof[i] = StorageResult{hexKey, (*hexutil.Big)(statedb.GetState(address, key).Big()), toHexSlice(proof)}
+	}
+
+	accountProof, err := statedb.GetProof(address)
+	if err != nil {
+		return nil, fmt.Errorf("account proof: %w", err)
+	}
+	return &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        hexutil.Uint64(statedb.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}, statedb.Error()
+}
+
+// CallArgs are the eth_call transaction arguments. Fee fields are accepted but ignored, calls are executed without base-fee.
+type CallArgs struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+	Input    *hexutil.Bytes  `json:"input"`
+}
+
+func (args *CallArgs) toMessage(globalGasCap uint64) types.Message {
+	var from common.Address
+	if args.From != nil {
+		from = *args.From
+	}
+	gas := globalGasCap
+	if args.Gas != nil && uint64(*args.Gas) < gas {
+		gas = uint64(*args.Gas)
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+	return types.NewMessage(from, args.To, 0, value, gas, new(big.Int), new(big.Int), new(big.Int), data, nil, true)
+}
+
+func (api *DebugAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	statedb, header, err := api.stateAndHeader(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	msg := args.toMessage(header.GasLimit)
+	blockCtx := core.NewEVMBlockContext(header, api.engine.chainCtx, nil)
+	evm := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.engine.l2Cfg, vm.Config{NoBaseFee: true})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return nil, fmt.Errorf("err: %w (supplied gas %d)", err, msg.Gas())
+	}
+	// missing state reads as empty during execution, which could change the result or make the call revert
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		if revert := result.Revert(); len(revert) > 0 {
+			return nil, fmt.Errorf("%w: %s", result.Err, hexutil.Bytes(revert))
+		}
+		return nil, result.Err
+	}
+	return result.Return(), nil
+}
+
+func (api *DebugAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	block, ok := api.blockByNumber(number)
+	if !ok {
+		return nil, nil
+	}
+	return rpcMarshalBlock(block, fullTx, types.MakeSigner(api.engine.l2Cfg, block.Number()))
+}
+
+// rpcMarshalBlock converts the block into the JSON-RPC block format.
+func rpcMarshalBlock(block *types.Block, fullTx bool, signer types.Signer) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	headerJSON, err := json.Marshal(block.Header())
+	if err != nil {
+		return nil, fmt.Errorf("encoding header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &fields); err != nil {
+		return nil, fmt.Errorf("decoding header fields: %w", err)
+	}
+	fields["size"] = hexutil.Uint64(block.Size())
+	fields["uncles"] = []common.Hash{}
+
+	txs := make([]interface{}, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		if !fullTx {
+			txs[i] = tx.Hash()
+			continue
+		}
+		txFields, err := rpcMarshalTransaction(tx, block, uint64(i), signer)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		txs[i] = txFields
+	}
+	fields["transactions"] = txs
+	return fields, nil
+}
+
+func rpcMarshalTransaction(tx *types.Transaction, block *types.Block, index uint64, signer types.Signer) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	txJSON, err := tx.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding tx: %w", err)
+	}
+	if err := json.Unmarshal(txJSON, &fields); err != nil {
+		return nil, fmt.Errorf("decoding tx fields: %w", err)
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, fmt.Errorf("tx sender: %w", err)
+	}
+	fields["from"] = from
+	fields["blockHash"] = block.Hash()
+	fields["blockNumber"] = (*hexutil.Big)(block.Number())
+	fields["transactionIndex"] = hexutil.Uint64(index)
+	return fields, nil
+}
+
+// decodeStorageKey parses a hex-encoded storage slot of up to 32 bytes, with optional 0x prefix.
+func decodeStorageKey(s string) (common.Hash, error) {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid hex storage key: %w", err)
+	}
+	if len(b) > 32 {
+		return common.Hash{}, fmt.Errorf("storage key too long, got %d bytes", len(b))
+	}
+	return common.BytesToHash(b), nil
+}
+
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
@@ -8,9 +8,7 @@ import (
 	"fmt"
 	"math/big"
 
-	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
 	"github.com/ethereum-optimism/optimism/op-node/eth"
-	"github.com/ethereum-optimism/optimism/op-node/rollup"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	beaconConsensus "github.com/ethereum/go-ethereum/consensus/beacon"
@@ -20,6 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -58,6 +57,7 @@ type EngineAPI struct {
 
 	// L2 evm / chain
 	l2Database ethdb.Database
+	preDB      *OracleBackedDB
 	l2Cfg      *params.ChainConfig
 
 	// L2 block building data
@@ -71,6 +71,18 @@ type EngineAPI struct {
 	l2TxFailed       []*types.Transaction      // log of failed transactions which could not be included
 
 	payloadID beacon.PayloadID // ID of payload that is currently being built
+
+	exporter *BlockExporter // optional, writes debug data of every built block
+	tracer   *TxTracer      // optional, traces every executed transaction
+
+	verifier      *ReferenceVerifier             // optional, compares every inserted block with a reference chain
+	blockReceipts map[common.Hash]types.Receipts // receipts of built or executed blocks, kept until verified
+	verifyErrors  int                            // consecutive failures to verify a block, like reference RPC outages
+	haltErr       error                          // critical error that must stop the derivation
+
+	outputs OutputRootSink // optional, receives the output root of inserted blocks
+
+	checkpoints *Checkpointer // optional, persists inserted blocks and checkpoints of the run
 }
 
 func NewEngineAPI(log log.Logger, cfg *params.ChainConfig, chain *OracleBackedL2Chain, preDB *OracleBackedDB) *EngineAPI {
@@ -87,11 +99,15 @@ func NewEngineAPI(log log.Logger, cfg *params.ChainConfig, chain *OracleBackedL2
 		safe:       chain.head.Hash(),
 		finalized:  eth.BlockID{Hash: chain.head.Hash(), Number: chain.head.NumberU64()},
 		l2Database: preDB,
+		preDB:      preDB,
 		l2Cfg:      cfg,
 		// building state starts nil
 	}
 }
 
+// maxVerifyAttempts is how often the derivation may retry a payload that could not be verified, before the engine halts.
+const maxVerifyAttempts = 5
+
 var (
 	STATUS_INVALID         = &eth.ForkchoiceUpdatedResult{PayloadStatus: eth.PayloadStatusV1{Status: eth.ExecutionInvalid}, PayloadID: nil}
 	STATUS_SYNCING         = &eth.ForkchoiceUpdatedResult{PayloadStatus: eth.PayloadStatusV1{Status: eth.ExecutionSyncing}, PayloadID: nil}
@@ -118,15 +134,63 @@ func computePayloadId(headBlockHash common.Hash, params *eth.PayloadAttributes)
 	return out
 }
 
+// SetBlockExporter enables the export of receipts, failed transactions and state diffs of every built block.
+func (ea *EngineAPI) SetBlockExporter(exporter *BlockExporter) {
+	ea.exporter = exporter
+}
+
+// SetTxTracer enables tracing of every transaction executed during block building and payload execution.
+func (ea *EngineAPI) SetTxTracer(tracer *TxTracer) {
+	ea.tracer = tracer
+}
+
+// SetReferenceVerifier enables the comparison of every inserted block with the reference chain.
+// The first mismatch halts the engine.
+func (ea *EngineAPI) SetReferenceVerifier(verifier *ReferenceVerifier) {
+	ea.verifier = verifier
+	ea.blockReceipts = make(map[common.Hash]types.Receipts)
+}
+
+// SetOutputRootSink enables computing the output root of inserted blocks, the sink selects which blocks.
+func (ea *EngineAPI) SetOutputRootSink(outputs OutputRootSink) {
+	ea.outputs = outputs
+}
+
+// SetCheckpointer enables persisting the inserted blocks, and checkpoints of the run including the state written by the engine.
+func (ea *EngineAPI) SetCheckpointer(checkpoints *Checkpointer) {
+	ea.checkpoints = checkpoints
+	ea.preDB.TrackWrites()
+}
+
+// Halted returns the error that caused the engine to halt, or nil if the engine can continue.
+// The derivation pipeline retries failed engine calls, this error signals that it should not.
+// Failures to load L2 blocks and state halt the engine too, these are not passed on by geth.
+func (ea *EngineAPI) Halted() error {
+	if ea.haltErr != nil {
+		return ea.haltErr
+	}
+	if err := ea.chain.Err(); err != nil {
+		return err
+	}
+	return ea.preDB.Err()
+}
+
+// stateDatabase opens the L2 state, recording key preimages if these are needed for block exports.
+func (ea *EngineAPI) stateDatabase() state.Database {
+	return state.NewDatabaseWithConfig(ea.l2Database, &trie.Config{Preimages: ea.exporter != nil})
+}
+
 func (ea *EngineAPI) L2OutputRoot() (eth.Bytes32, error) {
-	l2OutputVersion := eth.Bytes32{}
-	outBlock := ea.chain.currentBlock()
-	stateDB, err := state.New(outBlock.Root(), state.NewDatabase(ea.l2Database), nil)
+	out, err := ea.HeadOutputRoot()
 	if err != nil {
-		return eth.Bytes32{}, fmt.Errorf("failed to open L2 state db at block %s: %w", outBlock.Hash(), err)
+		return eth.Bytes32{}, err
 	}
-	withdrawalsTrie := stateDB.StorageTrie(predeploys.L2ToL1MessagePasserAddr)
-	return rollup.ComputeL2OutputRoot(l2OutputVersion, outBlock.Hash(), outBlock.Root(), withdrawalsTrie.Hash()), nil
+	return out.OutputRoot, nil
+}
+
+// HeadOutputRoot computes the output root of the current head, with its components.
+func (ea *EngineAPI) HeadOutputRoot() (*OutputRoot, error) {
+	return ea.OutputRootAt(ea.chain.currentBlock())
 }
 
 func (ea *EngineAPI) setFinalized(id eth.BlockID) {
@@ -146,7 +210,7 @@ func (ea *EngineAPI) startBlock(parent common.Hash, params *eth.PayloadAttribute
 	if parentHeader == nil {
 		return fmt.Errorf("uknown parent block: %s", parent)
 	}
-	statedb, err := state.New(parentHeader.Root, state.NewDatabase(ea.l2Database), nil)
+	statedb, err := state.New(parentHeader.Root, ea.stateDatabase(), nil)
 	if err != nil {
 		return fmt.Errorf("failed to init state db around block %s (state %s): %w", parent, parentHeader.Root, err)
 	}
@@ -170,6 +234,7 @@ func (ea *EngineAPI) startBlock(parent common.Hash, params *eth.PayloadAttribute
 	ea.l2Transactions = make([]*types.Transaction, 0)
 	ea.pendingIndices = make(map[common.Address]uint64)
 	ea.l2ForceEmpty = params.NoTxPool
+	ea.l2TxFailed = make([]*types.Transaction, 0)
 	ea.l2GasPool = new(core.GasPool).AddGas(header.GasLimit)
 	ea.payloadID = computePayloadId(parent, params)
 
@@ -179,11 +244,14 @@ func (ea *EngineAPI) startBlock(parent common.Hash, params *eth.PayloadAttribute
 		if err := tx.UnmarshalBinary(otx); err != nil {
 			return fmt.Errorf("transaction %d is not valid: %w", i, err)
 		}
-		ea.l2BuildingState.Prepare(tx.Hash(), i)
-		receipt, err := core.ApplyTransaction(ea.l2Cfg, ea.chainCtx, &ea.l2BuildingHeader.Coinbase,
-			ea.l2GasPool, ea.l2BuildingState, ea.l2BuildingHeader, &tx, &ea.l2BuildingHeader.GasUsed, ea.vmCfg)
+		receipt, err := ea.applyTransaction(ea.l2GasPool, ea.l2BuildingState, ea.l2BuildingHeader, &tx, i, &ea.l2BuildingHeader.GasUsed)
 		if err != nil {
 			ea.l2TxFailed = append(ea.l2TxFailed, &tx)
+			if ea.exporter != nil {
+				if err := ea.exporter.ExportFailed(header.Number.Uint64(), ea.payloadID, ea.l2TxFailed); err != nil {
+					ea.log.Error("failed to export failed transactions", "err", err)
+				}
+			}
 			return fmt.Errorf("failed to apply deposit transaction to L2 block (tx %d): %w", i, err)
 		}
 		ea.l2Receipts = append(ea.l2Receipts, receipt)
@@ -192,6 +260,31 @@ func (ea *EngineAPI) startBlock(parent common.Hash, params *eth.PayloadAttribute
 	return nil
 }
 
+// applyTransaction applies the transaction with the given index in the block to the state,
+// and writes a trace of the execution if tracing is enabled.
+func (ea *EngineAPI) applyTransaction(gp *core.GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, index int, usedGas *uint64) (*types.Receipt, error) {
+	statedb.Prepare(tx.Hash(), index)
+	if ea.tracer == nil {
+		return core.ApplyTransaction(ea.l2Cfg, ea.chainCtx, &header.Coinbase, gp, statedb, header, tx, usedGas, ea.vmCfg)
+	}
+	tracer, err := ea.tracer.newTracer(&tracers.Context{TxIndex: index, TxHash: tx.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("creating tracer: %w", err)
+	}
+	vmCfg := ea.vmCfg
+	vmCfg.Debug = true
+	vmCfg.Tracer = tracer
+	receipt, err := core.ApplyTransaction(ea.l2Cfg, ea.chainCtx, &header.Coinbase, gp, statedb, header, tx, usedGas, vmCfg)
+	if err != nil {
+		// transactions that cannot be applied are not executed, there is nothing to trace
+		return nil, err
+	}
+	if err := ea.tracer.writeResult(header.Number.Uint64(), index, tx.Hash(), tracer); err != nil {
+		return nil, fmt.Errorf("writing trace of tx %d: %w", index, err)
+	}
+	return receipt, nil
+}
+
 func (ea *EngineAPI) endBlock() (*types.Block, error) {
 	if ea.l2BuildingHeader == nil {
 		return nil, fmt.Errorf("no block is being built currently (id %s)", ea.payloadID)
@@ -211,9 +304,88 @@ func (ea *EngineAPI) endBlock() (*types.Block, error) {
 	if err := ea.l2BuildingState.Database().TrieDB().Commit(root, false, nil); err != nil {
 		return nil, fmt.Errorf("l2 trie write error: %w", err)
 	}
+	if ea.exporter != nil {
+		if err := ea.exportBlock(block, ea.l2BuildingState, ea.l2Receipts, ea.l2TxFailed); err != nil {
+			return nil, fmt.Errorf("l2 block export error: %w", err)
+		}
+	}
+	// remember the block, so it is not executed again when it is inserted with NewPayload
+	ea.chain.blocks[block.Hash()] = block
+	if ea.verifier != nil {
+		ea.blockReceipts[block.Hash()] = ea.l2Receipts
+	}
 	return block, nil
 }
 
+// executeBlock applies the transactions of a block that was not built by this engine on top of the parent state,
+// verifies the result against the block header, and writes the state changes to the db.
+func (ea *EngineAPI) executeBlock(block *types.Block, parent *types.Header) error {
+	statedb, err := state.New(parent.Root, ea.stateDatabase(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to init state db around block %s (state %s): %w", parent.Hash(), parent.Root, err)
+	}
+	header := block.Header()
+	gp := new(core.GasPool).AddGas(header.GasLimit)
+	receipts := make(types.Receipts, 0, len(block.Transactions()))
+	var usedGas uint64
+	for i, tx := range block.Transactions() {
+		receipt, err := ea.applyTransaction(gp, statedb, header, tx, i, &usedGas)
+		if err != nil {
+			return fmt.Errorf("could not apply tx %d [%s]: %w", i, tx.Hash(), err)
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	if usedGas != header.GasUsed {
+		return fmt.Errorf("invalid gas used (remote: %d local: %d)", header.GasUsed, usedGas)
+	}
+	if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+		return fmt.Errorf("invalid bloom (remote: %x local: %x)", header.Bloom, bloom)
+	}
+	if receiptSha := types.DeriveSha(receipts, trie.NewStackTrie(nil)); receiptSha != header.ReceiptHash {
+		return fmt.Errorf("invalid receipt root hash (remote: %s local: %s)", header.ReceiptHash, receiptSha)
+	}
+	if root := statedb.IntermediateRoot(ea.l2Cfg.IsEIP158(header.Number)); root != header.Root {
+		return fmt.Errorf("invalid merkle root (remote: %s local: %s)", header.Root, root)
+	}
+
+	root, err := statedb.Commit(ea.l2Cfg.IsEIP158(header.Number))
+	if err != nil {
+		return fmt.Errorf("l2 state write error: %w", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false, nil); err != nil {
+		return fmt.Errorf("l2 trie write error: %w", err)
+	}
+	if ea.exporter != nil {
+		if err := ea.exportBlock(block, statedb, receipts, nil); err != nil {
+			return fmt.Errorf("l2 block export error: %w", err)
+		}
+	}
+	if ea.verifier != nil {
+		ea.blockReceipts[block.Hash()] = receipts
+	}
+	return nil
+}
+
+func (ea *EngineAPI) exportBlock(block *types.Block, statedb *state.StateDB, receipts types.Receipts, failed []*types.Transaction) error {
+	parentHeader := ea.chain.getHeaderByHash(block.ParentHash())
+	if parentHeader == nil {
+		return fmt.Errorf("unknown parent block: %s", block.ParentHash())
+	}
+	diff, err := DiffState(statedb.Database(), parentHeader.Root, block.Root())
+	if err != nil {
+		return fmt.Errorf("state diff: %w", err)
+	}
+	// fill in the block hash, log indices and other derived receipt fields that are unknown during execution
+	if err := receipts.DeriveFields(ea.l2Cfg, block.Hash(), block.NumberU64(), block.Transactions()); err != nil {
+		return fmt.Errorf("deriving receipt fields: %w", err)
+	}
+	if failed == nil {
+		failed = make([]*types.Transaction, 0)
+	}
+	return ea.exporter.ExportBlock(block, receipts, failed, diff)
+}
+
 func (ea *EngineAPI) GetPayload(ctx context.Context, payloadId eth.PayloadID) (*eth.ExecutionPayload, error) {
 	ea.log.Info("L2Engine API request received", "method", "GetPayload", "id", payloadId)
 	if ea.payloadID != payloadId {
@@ -255,7 +427,8 @@ func (ea *EngineAPI) ForkchoiceUpdate(ctx context.Context, state *eth.Forkchoice
 		// generating the payload. It's a special corner case that a few slots are
 		// missing and we are requested to generate the payload in slot.
 	} else if ea.l2Cfg.Optimism == nil { // minor L2Engine API divergence: allow proposers to reorg their own chain
-		panic("engine not configured as optimism engine")
+		ea.haltErr = errors.New("engine not configured as optimism engine")
+		return nil, ea.haltErr
 	}
 
 	// If the beacon client also advertised a finalized block, mark the local
@@ -328,16 +501,46 @@ func (ea *EngineAPI) NewPayload(ctx context.Context, payload *eth.ExecutionPaylo
 		log.Debug("Invalid NewPayload params", "params", payload, "error", err)
 		return &eth.PayloadStatusV1{Status: eth.ExecutionInvalidBlockHash}, nil
 	}
-	// TODO: skipping known-block check
 	// TODO: skipping invalid ancestor check (i.e. not remembering previously failed blocks)
 
-	parent := ea.chain.getBlockInfoByHash(block.ParentHash())
+	parent := ea.chain.getHeaderByHash(block.ParentHash())
 	if parent == nil {
 		// TODO: hack, saying we accepted if we don't know the parent block. Might want to return critical error if we can't actually sync.
 		return &eth.PayloadStatusV1{Status: eth.ExecutionAccepted, LatestValidHash: nil}, nil
 	}
+	// Blocks built by this engine are known already, any other block is executed before it is inserted.
+	if _, ok := ea.chain.knownBlock(block.Hash()); !ok {
+		if err := ea.executeBlock(block, parent); err != nil {
+			ea.log.Warn("Invalid NewPayload block", "hash", block.Hash(), "err", err)
+			return ea.invalid(err, parent), nil
+		}
+	}
+	if ea.verifier != nil {
+		// the receipts are kept until the block is verified, a retry of the payload does not execute the block again
+		if err := ea.verifier.VerifyBlock(ctx, block, ea.blockReceipts[block.Hash()]); err != nil {
+			var mismatch *MismatchError
+			if ea.verifyErrors++; errors.As(err, &mismatch) || ea.verifyErrors >= maxVerifyAttempts {
+				ea.haltErr = err
+			}
+			return nil, err
+		}
+		ea.verifyErrors = 0
+		delete(ea.blockReceipts, block.Hash())
+	}
 	ea.chain.blocks[block.Hash()] = block // TODO setter
 	ea.chain.SetHead(eth.HeaderBlockInfo(block.Header()))
+	if ea.outputs != nil && ea.outputs.Include(block.NumberU64()) {
+		if err := ea.addOutputRoot(block); err != nil {
+			ea.haltErr = err
+			return nil, err
+		}
+	}
+	if ea.checkpoints != nil {
+		if err := ea.checkpoint(ctx, block); err != nil {
+			ea.haltErr = err
+			return nil, err
+		}
+	}
 	// TODO: Don't log the json...
 	json, _ := block.Header().MarshalJSON()
 	ea.log.Info("Produced block", "block", string(json))
@@ -345,6 +548,36 @@ func (ea *EngineAPI) NewPayload(ctx context.Context, payload *eth.ExecutionPaylo
 	return &eth.PayloadStatusV1{Status: eth.ExecutionValid, LatestValidHash: &hash}, nil
 }
 
+// checkpoint stores the inserted block, and writes a checkpoint if the block is at a checkpoint interval.
+func (ea *EngineAPI) checkpoint(ctx context.Context, block *types.Block) error {
+	if err := ea.checkpoints.storeBlock(block); err != nil {
+		return fmt.Errorf("storing block %s: %w", block.Hash(), err)
+	}
+	if !ea.checkpoints.include(block.NumberU64()) {
+		return nil
+	}
+	head, err := ea.chain.L2BlockRefByHash(ctx, block.Hash())
+	if err != nil {
+		return err
+	}
+	if err := ea.checkpoints.writeCheckpoint(ea.preDB, head); err != nil {
+		return fmt.Errorf("writing checkpoint at block %s: %w", head, err)
+	}
+	ea.log.Info("Wrote checkpoint", "head", head)
+	return nil
+}
+
+func (ea *EngineAPI) addOutputRoot(block *types.Block) error {
+	out, err := ea.OutputRootAt(eth.HeaderBlockInfo(block.Header()))
+	if err != nil {
+		return fmt.Errorf("computing output root of block %d: %w", block.NumberU64(), err)
+	}
+	if err := ea.outputs.Add(out); err != nil {
+		return fmt.Errorf("adding output root of block %d: %w", block.NumberU64(), err)
+	}
+	return nil
+}
+
 func (ea *EngineAPI) invalid(err error, latestValid *types.Header) *eth.PayloadStatusV1 {
 	currentHash := ea.chain.currentBlock().Hash()
 	if latestValid != nil {
@@ -0,0 +1,62 @@
+package l2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockExporter writes the receipts, failed transactions and state diff of every block built by the engine
+// to a directory, with one sub-directory per block.
+type BlockExporter struct {
+	dir string
+}
+
+func NewBlockExporter(dir string) (*BlockExporter, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("create export dir: %w", err)
+	}
+	return &BlockExporter{dir: dir}, nil
+}
+
+// ExportBlock writes receipts.json, failed.json and statediff.json into the directory of the block.
+func (e *BlockExporter) ExportBlock(block *types.Block, receipts types.Receipts, failed []*types.Transaction, diff *StateDiff) error {
+	blockDir := filepath.Join(e.dir, fmt.Sprintf("%d-%s", block.NumberU64(), block.Hash()))
+	if err := os.MkdirAll(blockDir, 0777); err != nil {
+		return fmt.Errorf("create block export dir: %w", err)
+	}
+	if err := writeJSON(filepath.Join(blockDir, "receipts.json"), receipts); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(blockDir, "failed.json"), failed); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(blockDir, "statediff.json"), diff)
+}
+
+// ExportFailed writes failed.json for a block that could not be built.
+func (e *BlockExporter) ExportFailed(number uint64, payloadID beacon.PayloadID, failed []*types.Transaction) error {
+	blockDir := filepath.Join(e.dir, fmt.Sprintf("%d-failed-%s", number, payloadID))
+	if err := os.MkdirAll(blockDir, 0777); err != nil {
+		return fmt.Errorf("create block export dir: %w", err)
+	}
+	return writeJSON(filepath.Join(blockDir, "failed.json"), failed)
+}
+
+func writeJSON(path string, v interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("writing %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -14,25 +14,51 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// Client is the part of the L2 JSON-RPC API that the LoadingL2Oracle uses.
+type Client interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	// DbGet reads a pre-image from the database of the node with debug_dbGet.
+	DbGet(ctx context.Context, key common.Hash) ([]byte, error)
+}
+
+// RPCClient implements Client with a single JSON-RPC endpoint.
+type RPCClient struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+var _ Client = (*RPCClient)(nil)
+
+func NewRPCClient(client *rpc.Client) *RPCClient {
+	return &RPCClient{Client: ethclient.NewClient(client), rpc: client}
+}
+
+func (c *RPCClient) DbGet(ctx context.Context, key common.Hash) ([]byte, error) {
+	var value hexutil.Bytes
+	if err := c.rpc.CallContext(ctx, &value, "debug_dbGet", key.Hex()); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
 // LoadingL2Oracle is an implementation of oracle.L2Oracle that loads content from another node via JSON-RPC API.
+// Content is read from a store.Source first, and only loaded if it is not there.
 // Loaded data is written to a store.BlockStore to make the pre-image data available for later execution without needing another node.
 type LoadingL2Oracle struct {
-	logger    log.Logger
-	rpcClient *rpc.Client
-	client    *ethclient.Client
-	store     store.BlockStore
-	source    store.BlockSource
+	logger log.Logger
+	client Client
+	store  store.BlockStore
+	source store.BlockSource
 }
 
 var _ oracle.L2Oracle = (*LoadingL2Oracle)(nil)
 
-func NewLoadingL2Chain(logger log.Logger, l2RpcClient *rpc.Client, sstore store.Store, source store.Source) *LoadingL2Oracle {
+func NewLoadingL2Chain(logger log.Logger, client Client, sstore store.Store, source store.Source) *LoadingL2Oracle {
 	return &LoadingL2Oracle{
-		logger:    logger,
-		rpcClient: l2RpcClient,
-		client:    ethclient.NewClient(l2RpcClient),
-		store:     store.BlockStore{Store: sstore},
-		source:    store.BlockSource{Source: source},
+		logger: logger,
+		client: client,
+		store:  store.BlockStore{Store: sstore},
+		source: store.BlockSource{Source: source},
 	}
 }
 
@@ -44,19 +70,23 @@ func (l *LoadingL2Oracle) FetchL2MPTNode(ctx context.Context, nodeHash common.Ha
 	} else if !store.IsNoDataError(err) {
 		return nil, fmt.Errorf("restoring node: %w", err)
 	}
-	var node hexutil.Bytes
-	err = l.rpcClient.CallContext(ctx, &node, "debug_dbGet", nodeHash.Hex())
+	node, err := l.client.DbGet(ctx, nodeHash)
 	if err != nil {
 		return nil, err
 	}
 	err = l.store.StoreNode(nodeHash, node)
-	l.logger.Debug("Loaded node", "key", nodeHash, "val", node)
+	l.logger.Debug("Loaded node", "key", nodeHash, "val", hexutil.Bytes(node))
 	return node, err
 }
 
 // FetchL2Block fetches L2 block with transactions
 func (l *LoadingL2Oracle) FetchL2Block(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
-	// TODO: try blocksource first; after ReadTransactions is implemented
+	sblock, err := l.source.ReadBlock(blockHash)
+	if err == nil {
+		return sblock, nil
+	} else if !store.IsNoDataError(err) {
+		return nil, fmt.Errorf("restoring block: %w", err)
+	}
 	block, err := l.client.BlockByHash(ctx, blockHash)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,158 @@
+package l2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// OutputRoot is the output root of an L2 block, together with the components it commits to.
+type OutputRoot struct {
+	Number                uint64      `json:"number"`
+	BlockHash             common.Hash `json:"blockHash"`
+	StateRoot             common.Hash `json:"stateRoot"`
+	WithdrawalStorageRoot common.Hash `json:"withdrawalStorageRoot"`
+	OutputRoot            eth.Bytes32 `json:"outputRoot"`
+}
+
+// OutputRootAt computes the output root of the given block from its state.
+func (ea *EngineAPI) OutputRootAt(block eth.BlockInfo) (*OutputRoot, error) {
+	l2OutputVersion := eth.Bytes32{}
+	withdrawalsTrie, err := messagePasserStorage(state.NewDatabase(ea.l2Database), block)
+	if err != nil {
+		return nil, err
+	}
+	withdrawalsRoot := withdrawalsTrie.Hash()
+	return &OutputRoot{
+		Number:                block.NumberU64(),
+		BlockHash:             block.Hash(),
+		StateRoot:             block.Root(),
+		WithdrawalStorageRoot: withdrawalsRoot,
+		OutputRoot:            rollup.ComputeL2OutputRoot(l2OutputVersion, block.Hash(), block.Root(), withdrawalsRoot),
+	}, nil
+}
+
+// messagePasserState opens the state of the block, and the storage trie of the L2ToL1MessagePasser in it.
+func (ea *EngineAPI) messagePasserState(block eth.BlockInfo) (*state.StateDB, state.Trie, error) {
+	db := state.NewDatabase(ea.l2Database)
+	stateDB, err := state.New(block.Root(), db, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open L2 state db at block %s: %w", block.Hash(), err)
+	}
+	withdrawalsTrie, err := messagePasserStorage(db, block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stateDB, withdrawalsTrie, nil
+}
+
+// messagePasserStorage opens the storage trie of the L2ToL1MessagePasser in the state of the block.
+func messagePasserStorage(db state.Database, block eth.BlockInfo) (state.Trie, error) {
+	// StateDB.StorageTrie falls back to an empty trie if the storage root cannot be loaded, and drops the error,
+	// so the account and its storage trie are opened directly to not compute an output root of missing state
+	accountTrie, err := db.OpenTrie(block.Root())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open L2 state trie at block %s: %w", block.Hash(), err)
+	}
+	acc, err := accountTrie.TryGetAccount(predeploys.L2ToL1MessagePasserAddr.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s account at block %s: %w", predeploys.L2ToL1MessagePasserAddr, block.Hash(), err)
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("missing %s account in L2 state at block %s", predeploys.L2ToL1MessagePasserAddr, block.Hash())
+	}
+	withdrawalsTrie, err := db.OpenStorageTrie(block.Root(), crypto.Keccak256Hash(predeploys.L2ToL1MessagePasserAddr.Bytes()), acc.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage at block %s: %w", predeploys.L2ToL1MessagePasserAddr, block.Hash(), err)
+	}
+	return withdrawalsTrie, nil
+}
+
+// OutputRootSink receives the output roots of inserted blocks.
+type OutputRootSink interface {
+	// Include returns whether the output root of the block with the given number should be added.
+	Include(number uint64) bool
+	Add(out *OutputRoot) error
+}
+
+// OutputRootWriter writes the output root of every Nth inserted block as a JSON line.
+type OutputRootWriter struct {
+	enc      *json.Encoder
+	interval uint64
+}
+
+var _ OutputRootSink = (*OutputRootWriter)(nil)
+
+func NewOutputRootWriter(w io.Writer, interval uint64) *OutputRootWriter {
+	if interval == 0 {
+		interval = 1
+	}
+	return &OutputRootWriter{enc: json.NewEncoder(w), interval: interval}
+}
+
+func (w *OutputRootWriter) Include(number uint64) bool {
+	return number%w.interval == 0
+}
+
+func (w *OutputRootWriter) Add(out *OutputRoot) error {
+	return w.enc.Encode(out)
+}
+
+// OutputRootCache keeps the output root of every inserted block in memory.
+type OutputRootCache struct {
+	outputs map[uint64]*OutputRoot
+}
+
+var _ OutputRootSink = (*OutputRootCache)(nil)
+
+func NewOutputRootCache() *OutputRootCache {
+	return &OutputRootCache{outputs: make(map[uint64]*OutputRoot)}
+}
+
+func (c *OutputRootCache) Include(number uint64) bool {
+	return true
+}
+
+func (c *OutputRootCache) Add(out *OutputRoot) error {
+	c.outputs[out.Number] = out
+	return nil
+}
+
+// Get returns the output root of the inserted block with the given number, if any.
+func (c *OutputRootCache) Get(number uint64) (*OutputRoot, bool) {
+	out, ok := c.outputs[number]
+	return out, ok
+}
+
+// OutputRootSinks passes the output roots on to several sinks, each only gets the blocks it includes.
+type OutputRootSinks []OutputRootSink
+
+var _ OutputRootSink = (OutputRootSinks)(nil)
+
+func (s OutputRootSinks) Include(number uint64) bool {
+	for _, sink := range s {
+		if sink.Include(number) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s OutputRootSinks) Add(out *OutputRoot) error {
+	for _, sink := range s {
+		if !sink.Include(out.Number) {
+			continue
+		}
+		if err := sink.Add(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
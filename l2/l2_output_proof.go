@@ -0,0 +1,107 @@
+package l2
+
+import (
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// OutputRootProof holds the components of an output root, like the Types.OutputRootProof struct of the L1 contracts,
+// and the merkle proof of the L2ToL1MessagePasser account against the state root.
+type OutputRootProof struct {
+	Version                  eth.Bytes32 `json:"version"`
+	StateRoot                common.Hash `json:"stateRoot"`
+	MessagePasserStorageRoot common.Hash `json:"messagePasserStorageRoot"`
+	LatestBlockhash          common.Hash `json:"latestBlockhash"`
+
+	BlockNumber uint64      `json:"blockNumber"`
+	OutputRoot  eth.Bytes32 `json:"outputRoot"`
+	// MessagePasserAccountProof proves the MessagePasserStorageRoot against the StateRoot
+	MessagePasserAccountProof []hexutil.Bytes `json:"messagePasserAccountProof"`
+	// ABIEncoded is the ABI encoding of the Types.OutputRootProof tuple
+	ABIEncoded hexutil.Bytes `json:"abiEncoded"`
+}
+
+// Bindings converts the proof to the op-bindings contract type.
+func (p *OutputRootProof) Bindings() bindings.TypesOutputRootProof {
+	return bindings.TypesOutputRootProof{
+		Version:                  p.Version,
+		StateRoot:                p.StateRoot,
+		MessagePasserStorageRoot: p.MessagePasserStorageRoot,
+		LatestBlockhash:          p.LatestBlockhash,
+	}
+}
+
+// HeadOutputRootProof computes the output root proof of the current head.
+func (ea *EngineAPI) HeadOutputRootProof() (*OutputRootProof, error) {
+	return ea.OutputRootProofAt(ea.chain.currentBlock())
+}
+
+// OutputRootProofAt computes the output root of the given block, with the proof of its components.
+func (ea *EngineAPI) OutputRootProofAt(block eth.BlockInfo) (*OutputRootProof, error) {
+	stateDB, withdrawalsTrie, err := ea.messagePasserState(block)
+	if err != nil {
+		return nil, err
+	}
+	accountProof, err := stateDB.GetProof(predeploys.L2ToL1MessagePasserAddr)
+	if err != nil {
+		return nil, fmt.Errorf("message passer account proof: %w", err)
+	}
+	if err := verifyAccountProof(block.Root(), predeploys.L2ToL1MessagePasserAddr, accountProof); err != nil {
+		return nil, fmt.Errorf("invalid message passer account proof: %w", err)
+	}
+
+	proof := &OutputRootProof{
+		StateRoot:                block.Root(),
+		MessagePasserStorageRoot: withdrawalsTrie.Hash(),
+		LatestBlockhash:          block.Hash(),
+		BlockNumber:              block.NumberU64(),
+	}
+	proof.OutputRoot = rollup.ComputeL2OutputRoot(proof.Version, proof.LatestBlockhash, proof.StateRoot, proof.MessagePasserStorageRoot)
+	for _, node := range accountProof {
+		proof.MessagePasserAccountProof = append(proof.MessagePasserAccountProof, node)
+	}
+	proof.ABIEncoded, err = encodeOutputRootProof(proof.Bindings())
+	if err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func verifyAccountProof(root common.Hash, addr common.Address, proof [][]byte) error {
+	db := memorydb.New()
+	for _, node := range proof {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return err
+		}
+	}
+	_, err := trie.VerifyProof(root, crypto.Keccak256(addr[:]), db)
+	return err
+}
+
+// encodeOutputRootProof ABI-encodes the proof as the Types.OutputRootProof argument of proveWithdrawalTransaction.
+func encodeOutputRootProof(proof bindings.TypesOutputRootProof) ([]byte, error) {
+	portalABI, err := bindings.OptimismPortalMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("loading OptimismPortal abi: %w", err)
+	}
+	method, ok := portalABI.Methods["proveWithdrawalTransaction"]
+	if !ok {
+		return nil, fmt.Errorf("OptimismPortal abi has no proveWithdrawalTransaction method")
+	}
+	for _, input := range method.Inputs {
+		if input.Name == "_outputRootProof" {
+			return abi.Arguments{input}.Pack(proof)
+		}
+	}
+	return nil, fmt.Errorf("proveWithdrawalTransaction has no _outputRootProof argument")
+}
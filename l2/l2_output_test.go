@@ -0,0 +1,113 @@
+package l2_test
+
+import (
+	"op-mordor/l2"
+	"op-mordor/program"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/require"
+)
+
+// newMessagePasserEngine creates an engine on top of a block whose state only holds the message passer with one
+// withdrawal slot, and returns the block and the storage root of the message passer.
+func newMessagePasserEngine(t *testing.T) (*l2.EngineAPI, *types.Block, common.Hash) {
+	s := store.NewMemoryStore()
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, err := state.New(types.EmptyRootHash, db, nil)
+	require.NoError(t, err)
+	statedb.SetNonce(predeploys.L2ToL1MessagePasserAddr, 1)
+	statedb.SetState(predeploys.L2ToL1MessagePasserAddr, common.Hash{1}, common.Hash{2})
+	root, err := statedb.Commit(false)
+	require.NoError(t, err)
+	require.NoError(t, db.TrieDB().Commit(root, false, nil))
+	it := db.DiskDB().NewIterator(nil, nil)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			require.NoError(t, s.StoreNode(common.BytesToHash(it.Key()), it.Value()))
+		}
+	}
+	it.Release()
+
+	// the storage root of a single slot, independent of the state db
+	storage := trie.NewEmpty(trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	value, err := rlp.EncodeToBytes(common.TrimLeftZeroes(common.Hash{2}.Bytes()))
+	require.NoError(t, err)
+	require.NoError(t, storage.TryUpdate(crypto.Keccak256(common.Hash{1}.Bytes()), value))
+
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:      common.Big1,
+		Root:        root,
+		Difficulty:  common.Big0,
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+	})
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(block))
+
+	_, rollupCfg, err := program.DefaultConfigs()
+	require.NoError(t, err)
+	oracle := l2.NewStoreL2Oracle(s)
+	chain := l2.NewOracleBackedL2Chain(eth.HeaderBlockInfo(block.Header()), oracle, rollupCfg)
+	return l2.NewEngineAPI(log.New(), params.TestChainConfig, chain, l2.NewOracleBackedDB(oracle)), block, storage.Hash()
+}
+
+func TestOutputRootProof(t *testing.T) {
+	engine, block, storageRoot := newMessagePasserEngine(t)
+
+	proof, err := engine.HeadOutputRootProof()
+	require.NoError(t, err)
+	require.Equal(t, block.Root(), proof.StateRoot)
+	require.Equal(t, storageRoot, proof.MessagePasserStorageRoot)
+	require.Equal(t, block.Hash(), proof.LatestBlockhash)
+	// version 0 output roots are the hash of the version, state root, storage root and block hash
+	want := crypto.Keccak256Hash(make([]byte, 32), block.Root().Bytes(), storageRoot.Bytes(), block.Hash().Bytes())
+	require.Equal(t, eth.Bytes32(want), proof.OutputRoot)
+	require.NotEmpty(t, proof.MessagePasserAccountProof)
+	require.NotEmpty(t, proof.ABIEncoded)
+
+	out, err := engine.HeadOutputRoot()
+	require.NoError(t, err)
+	require.Equal(t, proof.OutputRoot, out.OutputRoot)
+}
+
+func TestOutputRootSinks(t *testing.T) {
+	cache := l2.NewOutputRootCache()
+	var written []*l2.OutputRoot
+	sinks := l2.OutputRootSinks{&intervalSink{interval: 2, added: &written}, cache}
+
+	for number := uint64(1); number <= 4; number++ {
+		require.True(t, sinks.Include(number))
+		require.NoError(t, sinks.Add(&l2.OutputRoot{Number: number}))
+	}
+	require.Len(t, written, 2)
+	_, ok := cache.Get(3)
+	require.True(t, ok)
+	require.False(t, l2.OutputRootSinks{&intervalSink{interval: 2}}.Include(3))
+}
+
+// intervalSink collects the output roots of every Nth block.
+type intervalSink struct {
+	interval uint64
+	added    *[]*l2.OutputRoot
+}
+
+func (s *intervalSink) Include(number uint64) bool {
+	return number%s.interval == 0
+}
+
+func (s *intervalSink) Add(out *l2.OutputRoot) error {
+	*s.added = append(*s.added, out)
+	return nil
+}
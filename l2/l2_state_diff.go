@@ -0,0 +1,191 @@
+package l2
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// StateDiff lists all accounts and storage slots that differ between two state roots.
+type StateDiff struct {
+	ParentRoot common.Hash   `json:"parentRoot"`
+	Root       common.Hash   `json:"root"`
+	Accounts   []AccountDiff `json:"accounts"`
+}
+
+// AccountDiff is the change of a single account. Pre is nil for created accounts, Post is nil for deleted accounts.
+// The Address is only known if the preimage of the hashed account key was recorded by the state database.
+type AccountDiff struct {
+	AddressHash common.Hash     `json:"addressHash"`
+	Address     *common.Address `json:"address,omitempty"`
+	Pre         *AccountState   `json:"pre"`
+	Post        *AccountState   `json:"post"`
+	Storage     []StorageDiff   `json:"storage,omitempty"`
+}
+
+type AccountState struct {
+	Nonce       hexutil.Uint64 `json:"nonce"`
+	Balance     *hexutil.Big   `json:"balance"`
+	StorageRoot common.Hash    `json:"storageRoot"`
+	CodeHash    common.Hash    `json:"codeHash"`
+}
+
+// StorageDiff is the change of a single storage slot, absent slots are represented by a zero value.
+// Like with accounts, the Key is only known if the preimage of the hashed slot was recorded.
+type StorageDiff struct {
+	KeyHash common.Hash  `json:"keyHash"`
+	Key     *common.Hash `json:"key,omitempty"`
+	Pre     common.Hash  `json:"pre"`
+	Post    common.Hash  `json:"post"`
+}
+
+// DiffState computes the account and storage changes from parentRoot to root.
+// Only the sub-tries that differ between the two states are traversed.
+func DiffState(db state.Database, parentRoot common.Hash, root common.Hash) (*StateDiff, error) {
+	preTrie, err := db.OpenTrie(parentRoot)
+	if err != nil {
+		return nil, fmt.Errorf("opening parent state trie %s: %w", parentRoot, err)
+	}
+	postTrie, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, fmt.Errorf("opening state trie %s: %w", root, err)
+	}
+	preLeaves, postLeaves, err := diffLeaves(preTrie, postTrie)
+	if err != nil {
+		return nil, fmt.Errorf("diffing state tries: %w", err)
+	}
+
+	out := &StateDiff{ParentRoot: parentRoot, Root: root, Accounts: []AccountDiff{}}
+	for _, addrHash := range sortedKeys(preLeaves, postLeaves) {
+		diff := AccountDiff{AddressHash: addrHash}
+		if preimage := postTrie.GetKey(addrHash[:]); preimage != nil {
+			addr := common.BytesToAddress(preimage)
+			diff.Address = &addr
+		}
+		preRoot, postRoot := types.EmptyRootHash, types.EmptyRootHash
+		if blob, ok := preLeaves[addrHash]; ok {
+			acc, err := decodeAccount(blob)
+			if err != nil {
+				return nil, fmt.Errorf("pre-state account %s: %w", addrHash, err)
+			}
+			diff.Pre, preRoot = acc, acc.StorageRoot
+		}
+		if blob, ok := postLeaves[addrHash]; ok {
+			acc, err := decodeAccount(blob)
+			if err != nil {
+				return nil, fmt.Errorf("post-state account %s: %w", addrHash, err)
+			}
+			diff.Post, postRoot = acc, acc.StorageRoot
+		}
+		if preRoot != postRoot {
+			diff.Storage, err = diffStorage(db, parentRoot, root, addrHash, preRoot, postRoot)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", addrHash, err)
+			}
+		}
+		out.Accounts = append(out.Accounts, diff)
+	}
+	return out, nil
+}
+
+func diffStorage(db state.Database, parentRoot common.Hash, root common.Hash, addrHash common.Hash, preRoot common.Hash, postRoot common.Hash) ([]StorageDiff, error) {
+	preTrie, err := db.OpenStorageTrie(parentRoot, addrHash, preRoot)
+	if err != nil {
+		return nil, fmt.Errorf("opening parent storage trie %s: %w", preRoot, err)
+	}
+	postTrie, err := db.OpenStorageTrie(root, addrHash, postRoot)
+	if err != nil {
+		return nil, fmt.Errorf("opening storage trie %s: %w", postRoot, err)
+	}
+	preLeaves, postLeaves, err := diffLeaves(preTrie, postTrie)
+	if err != nil {
+		return nil, fmt.Errorf("diffing storage tries: %w", err)
+	}
+
+	var out []StorageDiff
+	for _, keyHash := range sortedKeys(preLeaves, postLeaves) {
+		diff := StorageDiff{KeyHash: keyHash}
+		if preimage := postTrie.GetKey(keyHash[:]); preimage != nil {
+			key := common.BytesToHash(preimage)
+			diff.Key = &key
+		}
+		if diff.Pre, err = decodeSlot(preLeaves[keyHash]); err != nil {
+			return nil, fmt.Errorf("pre-state slot %s: %w", keyHash, err)
+		}
+		if diff.Post, err = decodeSlot(postLeaves[keyHash]); err != nil {
+			return nil, fmt.Errorf("post-state slot %s: %w", keyHash, err)
+		}
+		out = append(out, diff)
+	}
+	return out, nil
+}
+
+// diffLeaves returns the leaves only present in the pre trie, and the leaves only present in the post trie.
+// Leaves with a changed value are part of both.
+func diffLeaves(preTrie state.Trie, postTrie state.Trie) (pre map[common.Hash][]byte, post map[common.Hash][]byte, err error) {
+	collect := func(a, b state.Trie) (map[common.Hash][]byte, error) {
+		// iterates over the nodes in b that are not in a
+		it, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+		leaves := make(map[common.Hash][]byte)
+		for it.Next(true) {
+			if it.Leaf() {
+				leaves[common.BytesToHash(it.LeafKey())] = common.CopyBytes(it.LeafBlob())
+			}
+		}
+		return leaves, it.Error()
+	}
+	if pre, err = collect(postTrie, preTrie); err != nil {
+		return nil, nil, err
+	}
+	if post, err = collect(preTrie, postTrie); err != nil {
+		return nil, nil, err
+	}
+	return pre, post, nil
+}
+
+func sortedKeys(a map[common.Hash][]byte, b map[common.Hash][]byte) []common.Hash {
+	keys := make([]common.Hash, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+	return keys
+}
+
+func decodeAccount(blob []byte) (*AccountState, error) {
+	var acc types.StateAccount
+	if err := rlp.DecodeBytes(blob, &acc); err != nil {
+		return nil, err
+	}
+	return &AccountState{
+		Nonce:       hexutil.Uint64(acc.Nonce),
+		Balance:     (*hexutil.Big)(acc.Balance),
+		StorageRoot: acc.Root,
+		CodeHash:    common.BytesToHash(acc.CodeHash),
+	}, nil
+}
+
+func decodeSlot(blob []byte) (common.Hash, error) {
+	if blob == nil {
+		return common.Hash{}, nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
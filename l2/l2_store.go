@@ -0,0 +1,41 @@
+package l2
+
+import (
+	"context"
+	"fmt"
+	"op-mordor/oracle"
+	"op-mordor/store"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// StoreL2Oracle is an implementation of oracle.L2Oracle that only reads pre-images from a store.Source.
+// Content that is not stored results in a store.NoDataError.
+type StoreL2Oracle struct {
+	source store.BlockSource
+}
+
+var _ oracle.L2Oracle = (*StoreL2Oracle)(nil)
+
+func NewStoreL2Oracle(source store.Source) *StoreL2Oracle {
+	return &StoreL2Oracle{source: store.BlockSource{Source: source}}
+}
+
+// FetchL2MPTNode fetches L2 state MPT node
+func (s *StoreL2Oracle) FetchL2MPTNode(ctx context.Context, nodeHash common.Hash) ([]byte, error) {
+	node, err := s.source.ReadNode(nodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading node: %w", err)
+	}
+	return node, nil
+}
+
+// FetchL2Block fetches L2 block with transactions
+func (s *StoreL2Oracle) FetchL2Block(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	block, err := s.source.ReadBlock(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("reading block: %w", err)
+	}
+	return block, nil
+}
@@ -0,0 +1,81 @@
+package l2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native" // registers the native call and prestate tracers
+)
+
+// TracerKind selects the geth tracer that is attached to every executed transaction.
+type TracerKind string
+
+const (
+	StructTracer   TracerKind = "struct"
+	CallTracer     TracerKind = "call"
+	PrestateTracer TracerKind = "prestate"
+)
+
+func (k TracerKind) Valid() bool {
+	switch k {
+	case StructTracer, CallTracer, PrestateTracer:
+		return true
+	default:
+		return false
+	}
+}
+
+// TxTracer creates a tracer per executed transaction, and writes the trace results to a directory,
+// one JSON file per transaction.
+type TxTracer struct {
+	kind TracerKind
+	dir  string
+}
+
+func NewTxTracer(kind TracerKind, dir string) (*TxTracer, error) {
+	if !kind.Valid() {
+		return nil, fmt.Errorf("unknown tracer kind %q", kind)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("create trace dir: %w", err)
+	}
+	return &TxTracer{kind: kind, dir: dir}, nil
+}
+
+func (t *TxTracer) newTracer(txCtx *tracers.Context) (tracers.Tracer, error) {
+	switch t.kind {
+	case StructTracer:
+		return logger.NewStructLogger(&logger.Config{}), nil
+	case CallTracer:
+		return tracers.New("callTracer", txCtx, nil)
+	case PrestateTracer:
+		return tracers.New("prestateTracer", txCtx, nil)
+	default:
+		return nil, fmt.Errorf("unknown tracer kind %q", t.kind)
+	}
+}
+
+// writeResult writes the trace of the transaction with the given index in the block with the given number.
+// Blocks are identified by number, since the hash of a block is not known yet while it is being built.
+func (t *TxTracer) writeResult(number uint64, index int, txHash common.Hash, tracer tracers.Tracer) error {
+	result, err := tracer.GetResult()
+	if err != nil {
+		return fmt.Errorf("trace result: %w", err)
+	}
+	name := fmt.Sprintf("%d-%d-%s-%s.json", number, index, txHash, t.kind)
+	return os.WriteFile(filepath.Join(t.dir, name), indentJSON(result), 0666)
+}
+
+func indentJSON(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,174 @@
+package l2
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReferenceClient is the part of the L2 JSON-RPC API that the ReferenceVerifier uses, as implemented by ethclient.Client.
+type ReferenceClient interface {
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+var _ ReferenceClient = (*ethclient.Client)(nil)
+
+// ReferenceVerifier compares every block inserted into the engine with the canonical block
+// at the same number of a reference L2 node.
+type ReferenceVerifier struct {
+	logger     log.Logger
+	client     ReferenceClient
+	reportPath string
+}
+
+// NewReferenceVerifier creates a verifier that writes a MismatchReport to reportPath on the first mismatch.
+func NewReferenceVerifier(logger log.Logger, client ReferenceClient, reportPath string) *ReferenceVerifier {
+	return &ReferenceVerifier{
+		logger:     logger,
+		client:     client,
+		reportPath: reportPath,
+	}
+}
+
+// MismatchReport describes the first block that differs from the reference chain.
+type MismatchReport struct {
+	Number        uint64          `json:"number"`
+	Hash          common.Hash     `json:"hash"`
+	ReferenceHash common.Hash     `json:"referenceHash"`
+	Fields        []FieldMismatch `json:"fields"`
+	Transactions  []TxMismatch    `json:"transactions"`
+}
+
+type FieldMismatch struct {
+	Field     string `json:"field"`
+	Derived   string `json:"derived"`
+	Reference string `json:"reference"`
+}
+
+// TxMismatch describes a transaction that differs, either by itself or by its receipt.
+// The derived or reference transaction hash is nil if the other block has more transactions.
+type TxMismatch struct {
+	Index            int             `json:"index"`
+	Hash             *common.Hash    `json:"hash"`
+	ReferenceHash    *common.Hash    `json:"referenceHash"`
+	ReceiptMismatch  []FieldMismatch `json:"receiptMismatch,omitempty"`
+	DerivedReceipt   *types.Receipt  `json:"derivedReceipt,omitempty"`
+	ReferenceReceipt *types.Receipt  `json:"referenceReceipt,omitempty"`
+}
+
+// MismatchError is returned when a block does not match the reference chain.
+type MismatchError struct {
+	Report *MismatchReport
+}
+
+func (e *MismatchError) Error() string {
+	fields := make([]string, len(e.Report.Fields))
+	for i, f := range e.Report.Fields {
+		fields[i] = f.Field
+	}
+	return fmt.Sprintf("block %d (%s) does not match reference block %s: %s differ, %d differing txs",
+		e.Report.Number, e.Report.Hash, e.Report.ReferenceHash, strings.Join(fields, ", "), len(e.Report.Transactions))
+}
+
+// VerifyBlock compares the block hash, state root and receipts root with the reference block.
+// The receipts are optional, and used to identify the differing transactions if the receipts root differs.
+func (v *ReferenceVerifier) VerifyBlock(ctx context.Context, block *types.Block, receipts types.Receipts) error {
+	ref, err := v.client.BlockByNumber(ctx, block.Number())
+	if err != nil {
+		return fmt.Errorf("fetching reference block %d: %w", block.NumberU64(), err)
+	}
+	report := &MismatchReport{
+		Number:        block.NumberU64(),
+		Hash:          block.Hash(),
+		ReferenceHash: ref.Hash(),
+	}
+	compare := func(field string, derived, reference common.Hash) {
+		if derived != reference {
+			report.Fields = append(report.Fields, FieldMismatch{Field: field, Derived: derived.Hex(), Reference: reference.Hex()})
+		}
+	}
+	compare("blockHash", block.Hash(), ref.Hash())
+	compare("stateRoot", block.Root(), ref.Root())
+	compare("receiptsRoot", block.ReceiptHash(), ref.ReceiptHash())
+	if len(report.Fields) == 0 {
+		v.logger.Debug("Block matches reference", "number", block.NumberU64(), "hash", block.Hash())
+		return nil
+	}
+	compare("parentHash", block.ParentHash(), ref.ParentHash())
+	compare("transactionsRoot", block.TxHash(), ref.TxHash())
+	if block.GasUsed() != ref.GasUsed() {
+		report.Fields = append(report.Fields, FieldMismatch{Field: "gasUsed",
+			Derived: hexutil.EncodeUint64(block.GasUsed()), Reference: hexutil.EncodeUint64(ref.GasUsed())})
+	}
+
+	report.Transactions, err = v.diffTransactions(ctx, block, ref, receipts)
+	if err != nil {
+		return fmt.Errorf("comparing transactions of block %d: %w", block.NumberU64(), err)
+	}
+	if err := writeJSON(v.reportPath, report); err != nil {
+		v.logger.Error("Failed to write mismatch report", "path", v.reportPath, "err", err)
+	}
+	mismatch := &MismatchError{Report: report}
+	v.logger.Error("Block does not match reference", "err", mismatch, "report", v.reportPath)
+	return mismatch
+}
+
+func (v *ReferenceVerifier) diffTransactions(ctx context.Context, block *types.Block, ref *types.Block, receipts types.Receipts) ([]TxMismatch, error) {
+	txs, refTxs := block.Transactions(), ref.Transactions()
+	out := []TxMismatch{}
+	for i := 0; i < len(txs) || i < len(refTxs); i++ {
+		var m TxMismatch
+		m.Index = i
+		if i < len(txs) {
+			h := txs[i].Hash()
+			m.Hash = &h
+		}
+		if i < len(refTxs) {
+			h := refTxs[i].Hash()
+			m.ReferenceHash = &h
+		}
+		if m.Hash == nil || m.ReferenceHash == nil || *m.Hash != *m.ReferenceHash {
+			out = append(out, m)
+			continue
+		}
+		// same transaction, compare the results, if we have them and they are expected to differ
+		if block.ReceiptHash() == ref.ReceiptHash() || i >= len(receipts) {
+			continue
+		}
+		refReceipt, err := v.client.TransactionReceipt(ctx, refTxs[i].Hash())
+		if err != nil {
+			return nil, fmt.Errorf("loading reference receipt for tx %s: %w", refTxs[i].Hash(), err)
+		}
+		m.ReceiptMismatch = diffReceipts(receipts[i], refReceipt)
+		if len(m.ReceiptMismatch) > 0 {
+			m.DerivedReceipt = receipts[i]
+			m.ReferenceReceipt = refReceipt
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func diffReceipts(receipt *types.Receipt, ref *types.Receipt) (out []FieldMismatch) {
+	compareUint := func(field string, derived, reference uint64) {
+		if derived != reference {
+			out = append(out, FieldMismatch{Field: field, Derived: hexutil.EncodeUint64(derived), Reference: hexutil.EncodeUint64(reference)})
+		}
+	}
+	compareUint("status", receipt.Status, ref.Status)
+	compareUint("gasUsed", receipt.GasUsed, ref.GasUsed)
+	compareUint("cumulativeGasUsed", receipt.CumulativeGasUsed, ref.CumulativeGasUsed)
+	compareUint("logs", uint64(len(receipt.Logs)), uint64(len(ref.Logs)))
+	if receipt.Bloom != ref.Bloom {
+		out = append(out, FieldMismatch{Field: "logsBloom", Derived: hexutil.Encode(receipt.Bloom[:]), Reference: hexutil.Encode(ref.Bloom[:])})
+	}
+	return out
+}
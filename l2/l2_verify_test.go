@@ -0,0 +1,77 @@
+package l2_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"op-mordor/l2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReference serves a fixed reference block and its receipts.
+type fakeReference struct {
+	block    *types.Block
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f *fakeReference) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return f.block, nil
+}
+
+func (f *fakeReference) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return f.receipts[txHash], nil
+}
+
+func TestReferenceVerifier(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	block, receipts := testutils.RandomBlock(rng, 2)
+	reportPath := filepath.Join(t.TempDir(), "mismatch.json")
+
+	t.Run("match", func(t *testing.T) {
+		v := l2.NewReferenceVerifier(log.New(), &fakeReference{block: block}, reportPath)
+		require.NoError(t, v.VerifyBlock(context.Background(), block, receipts))
+		require.NoFileExists(t, reportPath)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		// the reference executed the same transactions, but the first one used more gas
+		header := types.CopyHeader(block.Header())
+		header.Root = testutils.RandomHash(rng)
+		header.ReceiptHash = testutils.RandomHash(rng)
+		ref := types.NewBlockWithHeader(header).WithBody(block.Transactions(), nil)
+		refReceipts := make(map[common.Hash]*types.Receipt)
+		for i, tx := range block.Transactions() {
+			r := *receipts[i]
+			refReceipts[tx.Hash()] = &r
+		}
+		refReceipts[block.Transactions()[0].Hash()].GasUsed++
+
+		v := l2.NewReferenceVerifier(log.New(), &fakeReference{block: ref, receipts: refReceipts}, reportPath)
+		err := v.VerifyBlock(context.Background(), block, receipts)
+		var mismatch *l2.MismatchError
+		require.ErrorAs(t, err, &mismatch)
+		fields := make([]string, len(mismatch.Report.Fields))
+		for i, f := range mismatch.Report.Fields {
+			fields[i] = f.Field
+		}
+		require.ElementsMatch(t, []string{"blockHash", "stateRoot", "receiptsRoot"}, fields)
+		require.Len(t, mismatch.Report.Transactions, 1)
+		require.Equal(t, 0, mismatch.Report.Transactions[0].Index)
+		require.Equal(t, "gasUsed", mismatch.Report.Transactions[0].ReceiptMismatch[0].Field)
+
+		data, err := os.ReadFile(reportPath)
+		require.NoError(t, err)
+		var report l2.MismatchReport
+		require.NoError(t, json.Unmarshal(data, &report))
+		require.Equal(t, ref.Hash(), report.ReferenceHash)
+	})
+}
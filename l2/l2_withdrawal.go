@@ -0,0 +1,130 @@
+package l2
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/bindings"
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Withdrawal is a withdrawal message sent through the L2ToL1MessagePasser.
+type Withdrawal struct {
+	Nonce    *hexutil.Big   `json:"nonce"`
+	Sender   common.Address `json:"sender"`
+	Target   common.Address `json:"target"`
+	Value    *hexutil.Big   `json:"value"`
+	GasLimit *hexutil.Big   `json:"gasLimit"`
+	Data     hexutil.Bytes  `json:"data"`
+}
+
+// WithdrawalFromReceipt parses the withdrawal from the MessagePassed event in the receipt of the withdrawal transaction.
+func WithdrawalFromReceipt(receipt *types.Receipt) (*Withdrawal, error) {
+	ev, err := withdrawals.ParseMessagePassed(receipt)
+	if err != nil {
+		return nil, err
+	}
+	return &Withdrawal{
+		Nonce:    (*hexutil.Big)(ev.Nonce),
+		Sender:   ev.Sender,
+		Target:   ev.Target,
+		Value:    (*hexutil.Big)(ev.Value),
+		GasLimit: (*hexutil.Big)(ev.GasLimit),
+		Data:     ev.Data,
+	}, nil
+}
+
+// Bindings converts the withdrawal to the op-bindings contract type.
+func (w *Withdrawal) Bindings() bindings.TypesWithdrawalTransaction {
+	return bindings.TypesWithdrawalTransaction{
+		Nonce:    w.Nonce.ToInt(),
+		Sender:   w.Sender,
+		Target:   w.Target,
+		Value:    w.Value.ToInt(),
+		GasLimit: w.GasLimit.ToInt(),
+		Data:     w.Data,
+	}
+}
+
+// Hash computes the withdrawal hash, the key of the withdrawal in the sentMessages mapping.
+func (w *Withdrawal) Hash() (common.Hash, error) {
+	return withdrawals.WithdrawalHash(&bindings.L2ToL1MessagePasserMessagePassed{
+		Nonce:    w.Nonce.ToInt(),
+		Sender:   w.Sender,
+		Target:   w.Target,
+		Value:    w.Value.ToInt(),
+		GasLimit: w.GasLimit.ToInt(),
+		Data:     w.Data,
+	})
+}
+
+// WithdrawalProof holds the inputs of OptimismPortal.proveWithdrawalTransaction for a withdrawal.
+type WithdrawalProof struct {
+	Withdrawal      *Withdrawal      `json:"withdrawal"`
+	WithdrawalHash  common.Hash      `json:"withdrawalHash"`
+	StorageSlot     common.Hash      `json:"storageSlot"`
+	L2OutputIndex   *hexutil.Big     `json:"l2OutputIndex"`
+	OutputRootProof *OutputRootProof `json:"outputRootProof"`
+	// WithdrawalProof proves the sentMessages storage slot against the MessagePasserStorageRoot
+	WithdrawalProof []hexutil.Bytes `json:"withdrawalProof"`
+	// Calldata is the ABI-encoded proveWithdrawalTransaction call
+	Calldata hexutil.Bytes `json:"calldata"`
+}
+
+// WithdrawalProofAt proves that the withdrawal was sent in or before the given block.
+// The L2 output index is the index of the proposed output of the block in the L2OutputOracle.
+func (ea *EngineAPI) WithdrawalProofAt(block eth.BlockInfo, withdrawal *Withdrawal, l2OutputIndex *big.Int) (*WithdrawalProof, error) {
+	withdrawalHash, err := withdrawal.Hash()
+	if err != nil {
+		return nil, err
+	}
+	slot := withdrawals.StorageSlotOfWithdrawalHash(withdrawalHash)
+
+	stateDB, _, err := ea.messagePasserState(block)
+	if err != nil {
+		return nil, err
+	}
+	if sent := stateDB.GetState(predeploys.L2ToL1MessagePasserAddr, slot); sent != common.BigToHash(common.Big1) {
+		return nil, fmt.Errorf("withdrawal %s is not in sentMessages at block %s", withdrawalHash, block.Hash())
+	}
+	storageProof, err := stateDB.GetStorageProof(predeploys.L2ToL1MessagePasserAddr, slot)
+	if err != nil {
+		return nil, fmt.Errorf("sentMessages storage proof: %w", err)
+	}
+	outputRootProof, err := ea.OutputRootProofAt(block)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &WithdrawalProof{
+		Withdrawal:      withdrawal,
+		WithdrawalHash:  withdrawalHash,
+		StorageSlot:     slot,
+		L2OutputIndex:   (*hexutil.Big)(l2OutputIndex),
+		OutputRootProof: outputRootProof,
+	}
+	nodes := make([][]byte, len(storageProof))
+	for i, node := range storageProof {
+		nodes[i] = node
+		proof.WithdrawalProof = append(proof.WithdrawalProof, node)
+	}
+	portalABI, err := bindings.OptimismPortalMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("loading OptimismPortal abi: %w", err)
+	}
+	proof.Calldata, err = portalABI.Pack("proveWithdrawalTransaction", withdrawal.Bindings(), l2OutputIndex, outputRootProof.Bindings(), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("encoding proveWithdrawalTransaction call: %w", err)
+	}
+	return proof, nil
+}
+
+// HeadWithdrawalProof proves that the withdrawal was sent in or before the current head.
+func (ea *EngineAPI) HeadWithdrawalProof(withdrawal *Withdrawal, l2OutputIndex *big.Int) (*WithdrawalProof, error) {
+	return ea.WithdrawalProofAt(ea.chain.currentBlock(), withdrawal, l2OutputIndex)
+}
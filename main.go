@@ -1,95 +1,130 @@
 package main
 
 import (
-	"context"
-	_ "embed"
-	"encoding/json"
 	"fmt"
-	"op-mordor/derivation"
 	"op-mordor/l1"
 	"op-mordor/l2"
-	"op-mordor/oracle"
 	"os"
 
-	"github.com/ethereum-optimism/optimism/op-node/chaincfg"
 	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli"
 )
 
 var _ derive.Engine = (*l2.L2Engine)(nil)
 var _ derive.L1Fetcher = (*l1.OracleBackedL1Chain)(nil)
 
-//go:embed l2config.json
-var l2config []byte
-
 func main() {
-	setupEnv()
-	logger := log.New()
-	logger.SetHandler(log.StderrHandler)
-
-	l1Hash, l2Hash := parseCLIArgs(logger)
-
-	ctx := context.Background()
+	log.Root().SetHandler(log.StderrHandler)
 
-	var conf params.ChainConfig
-	err := json.Unmarshal(l2config, &conf)
-	if err != nil {
-		panic(fmt.Errorf("invalid l2config json: %w", err))
+	if err := newApp().Run(os.Args); err != nil {
+		log.Crit("Application failed", "message", err)
 	}
+}
 
-	// l2 config - genesis.json -
-	cfg := &chaincfg.Goerli
-	cfg.SeqWindowSize = 20
-	cfg.ChannelTimeout = 20
-
-	var l1Oracle oracle.L1Oracle
-	var l2Oracle oracle.L2Oracle
-	rpcMode := true // TODO switch between modes
-	// Instantiate one of the two oracle modes
-	if rpcMode {
-		l1Oracle, l2Oracle, err = setupRpcOracles(logger)
-		if err != nil {
-			panic(fmt.Errorf("setting up oracles: %w", err))
-		}
-	} else {
-		// TODO disk-mode (or future memory-mapped oracle)
-		panic("non-rpc oracles not implemented yet")
+// newApp creates the command tree of the CLI.
+func newApp() *cli.App {
+	app := cli.NewApp()
+	app.Name = "op-mordor"
+	app.Usage = "Derive and execute the L2 chain from L1 with oracle-backed pre-images"
+	app.Commands = []cli.Command{
+		{
+			Name:      "run",
+			Usage:     "Derive the L2 chain from the L1 head on top of the L2 head, and print the output root",
+			ArgsUsage: "<l1 head hash> <l2 head hash>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{modeFlag, verifyReportFlag, checkpointIntervalFlag, recordAccessLogFlag, witnessFlag, outputProofFlag, debugRpcAddrFlag}),
+			Action:    runCmd,
+		},
+		{
+			Name:      "prefetch",
+			Usage:     "Load all pre-images needed to derive from the L1 head on top of the L2 head from the RPCs into the store",
+			ArgsUsage: "<l1 head hash> <l2 head hash>",
+			Flags:     withFlags(commonFlags, []cli.Flag{checkpointIntervalFlag, recordAccessLogFlag, witnessFlag}),
+			Action:    prefetchCmd,
+		},
+		{
+			Name:      "replay",
+			Usage:     "Derive the L2 chain using only the pre-images in the store, optionally checking every request against an access log",
+			ArgsUsage: "<l1 head hash> <l2 head hash>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{replayAccessLogFlag, outputProofFlag}),
+			Action:    replayCmd,
+		},
+		{
+			Name:      "verify",
+			Usage:     "Derive the L2 chain and verify every derived block against the L2 RPC",
+			ArgsUsage: "<l1 head hash> <l2 head hash>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{modeFlag, verifyReportRequiredFlag, checkpointIntervalFlag, recordAccessLogFlag}),
+			Action:    verifyCmd,
+		},
+		{
+			Name:      "serve-engine",
+			Usage:     "Derive the L2 chain and serve the eth debug API over the derived state",
+			ArgsUsage: "<l1 head hash> <l2 head hash>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{modeFlag, verifyReportFlag, serveAddrFlag}),
+			Action:    serveEngineCmd,
+		},
+		{
+			Name:      "transition",
+			Usage:     "Run a single L2 state transition and print the output root",
+			ArgsUsage: "<l2 parent hash> <payload json file | l2 block hash>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{modeFlag, verifyReportFlag}),
+			Action:    transitionCmd,
+		},
+		{
+			Name:      "bisect",
+			Usage:     "Find the first L2 block where a disputed output root claim diverges from the derived chain",
+			ArgsUsage: "<l1 head hash> <l2 agreed block hash> <disputed block number> <claims jsonl file | rollup node rpc url>",
+			Flags:     withFlags(commonFlags, engineFlags, []cli.Flag{modeFlag, verifyReportFlag}),
+			Action:    bisectCmd,
+		},
+		{
+			Name:      "withdrawal",
+			Usage:     "Prove a withdrawal against the output root of an L2 block",
+			ArgsUsage: "<l2 block hash> <withdrawal tx hash | withdrawal json file> <l2 output index>",
+			Flags:     withFlags(commonFlags, []cli.Flag{modeFlag}),
+			Action:    withdrawalCmd,
+		},
+		{
+			Name:   "serve-preimages",
+			Usage:  "Serve the pre-images of the store over JSON-RPC, as a stand-in L1 and L2 RPC for the loading oracles",
+			Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag, preimageAddrFlag},
+			Action: servePreimagesCmd,
+		},
+		{
+			Name:        "store",
+			Usage:       "Inspect the pre-image store",
+			Subcommands: storeCommands,
+		},
 	}
+	return app
+}
 
-	l1Fetcher, err := l1.NewOracleBackedL1Chain(ctx, l1Oracle, l1Hash)
+// setup reads the configuration of the command and creates its logger.
+func setup(c *cli.Context) (*config, log.Logger, error) {
+	cfg, err := newConfig(c)
 	if err != nil {
-		panic(fmt.Errorf("creating L1: %w", err))
+		return nil, nil, err
 	}
-	l2Engine, err := l2.NewL2Engine(ctx, logger, &conf, l2Hash, l2Oracle, cfg)
+	logger, err := cfg.setupLogger()
 	if err != nil {
-		panic(fmt.Errorf("creating L2: %w", err))
+		return nil, nil, err
 	}
+	return cfg, logger, nil
+}
 
-	d := derivation.NewDerivation(logger, cfg, l1Fetcher, l2Engine)
-	out, err := d.Run()
-	if err != nil {
-		logger.Error("state fn crit err", "err", err)
-		os.Exit(1)
+func parseHash(name string, input string) (common.Hash, error) {
+	var h common.Hash
+	if err := h.UnmarshalText([]byte(input)); err != nil {
+		return common.Hash{}, fmt.Errorf("bad %s input: %w", name, err)
 	}
-	print(out.String())
-	os.Exit(0)
+	return h, nil
 }
 
-func parseCLIArgs(logger log.Logger) (common.Hash, common.Hash) {
-	if len(os.Args) != 3 {
-		logger.Error("unexpected number of arguments", "args", len(os.Args))
-		os.Exit(1)
-	}
-	var l1Hash, l2Hash common.Hash
-	if err := l1Hash.UnmarshalText([]byte(os.Args[1])); err != nil {
-		logger.Error("bad l1 hash input", "err", err)
-		os.Exit(1)
-	}
-	if err := l2Hash.UnmarshalText([]byte(os.Args[2])); err != nil {
-		logger.Error("bad l2 hash input", "err", err)
-		os.Exit(1)
+// expectArgs checks the number of positional arguments is within [min, max].
+func expectArgs(c *cli.Context, min int, max int) error {
+	if n := c.NArg(); n < min || n > max {
+		return fmt.Errorf("unexpected number of arguments %d, usage: %s %s", n, c.Command.Name, c.Command.ArgsUsage)
 	}
-	return l1Hash, l2Hash
+	return nil
 }
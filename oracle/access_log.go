@@ -0,0 +1,269 @@
+package oracle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AccessKind is the kind of pre-image requested from an oracle.
+type AccessKind string
+
+const (
+	L1HeaderAccess       AccessKind = "l1-header"
+	L1TransactionsAccess AccessKind = "l1-transactions"
+	L1ReceiptsAccess     AccessKind = "l1-receipts"
+	L2NodeAccess         AccessKind = "l2-node"
+	L2BlockAccess        AccessKind = "l2-block"
+)
+
+// Access is a single request served by an oracle. The size is the length of the RLP encoding of the served value,
+// or the length of the node for state nodes.
+type Access struct {
+	Kind AccessKind  `json:"kind"`
+	Key  common.Hash `json:"key"`
+	Size uint64      `json:"size"`
+}
+
+// ErrUnexpectedAccess is returned by replaying oracles when the program requests something else than recorded.
+var ErrUnexpectedAccess = errors.New("unexpected oracle access")
+
+// AccessLog writes every request served by the recording oracles to a writer, in order, as JSON lines.
+type AccessLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewAccessLog(w io.Writer) *AccessLog {
+	return &AccessLog{enc: json.NewEncoder(w)}
+}
+
+func (l *AccessLog) record(kind AccessKind, key common.Hash, size uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(&Access{Kind: kind, Key: key, Size: size}); err != nil {
+		return fmt.Errorf("recording oracle access: %w", err)
+	}
+	return nil
+}
+
+// ReadAccessLog reads an access log written by an AccessLog.
+func ReadAccessLog(r io.Reader) ([]Access, error) {
+	var out []Access
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a Access
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		out = append(out, a)
+	}
+	return out, scanner.Err()
+}
+
+// AccessReplay checks the requests of the replaying oracles against a recorded access log.
+type AccessReplay struct {
+	mu       sync.Mutex
+	accesses []Access
+	next     int
+	// err is the first mismatch, kept because callers like the trie database do not pass oracle errors on
+	err error
+}
+
+func NewAccessReplay(accesses []Access) *AccessReplay {
+	return &AccessReplay{accesses: accesses}
+}
+
+// expect checks that the next recorded access is for the given kind and key.
+func (r *AccessReplay) expect(kind AccessKind, key common.Hash) (Access, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.accesses) {
+		return Access{}, r.fail(fmt.Errorf("%w: %s %s after the %d recorded accesses", ErrUnexpectedAccess, kind, key, len(r.accesses)))
+	}
+	want := r.accesses[r.next]
+	if want.Kind != kind || want.Key != key {
+		return Access{}, r.fail(fmt.Errorf("%w: access %d is %s %s, recorded %s %s", ErrUnexpectedAccess, r.next, kind, key, want.Kind, want.Key))
+	}
+	r.next++
+	return want, nil
+}
+
+func checkSize(want Access, size uint64) error {
+	if want.Size != size {
+		return fmt.Errorf("%w: %s %s has size %d, recorded %d", ErrUnexpectedAccess, want.Kind, want.Key, size, want.Size)
+	}
+	return nil
+}
+
+// fail records the first mismatch. The lock must be held.
+func (r *AccessReplay) fail(err error) error {
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// Err returns the first mismatch between the requests and the recorded accesses, or nil.
+func (r *AccessReplay) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Done returns the first mismatch, or an error if not all recorded accesses were replayed.
+func (r *AccessReplay) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.next != len(r.accesses) {
+		return fmt.Errorf("%w: only %d of %d recorded accesses were replayed", ErrUnexpectedAccess, r.next, len(r.accesses))
+	}
+	return nil
+}
+
+// observer is called with every access served by a wrapped oracle.
+type observer func(kind AccessKind, key common.Hash, value interface{}) error
+
+// accessSize computes the size of a served value, as recorded in the access log.
+func accessSize(value interface{}) (uint64, error) {
+	if node, ok := value.([]byte); ok {
+		return uint64(len(node)), nil
+	}
+	data, err := rlp.EncodeToBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("encoding value to compute size: %w", err)
+	}
+	return uint64(len(data)), nil
+}
+
+// NewRecordingL1Oracle logs every request served by the L1 oracle to the access log.
+func NewRecordingL1Oracle(inner L1Oracle, log *AccessLog) L1Oracle {
+	return &observingL1Oracle{inner: inner, before: noCheck, after: log.observe}
+}
+
+// NewRecordingL2Oracle logs every request served by the L2 oracle to the access log.
+func NewRecordingL2Oracle(inner L2Oracle, log *AccessLog) L2Oracle {
+	return &observingL2Oracle{inner: inner, before: noCheck, after: log.observe}
+}
+
+// NewReplayL1Oracle serves the requests from the inner L1 oracle only if they match the recorded accesses.
+func NewReplayL1Oracle(inner L1Oracle, replay *AccessReplay) L1Oracle {
+	return &observingL1Oracle{inner: inner, before: replay.check, after: replay.checkValue}
+}
+
+// NewReplayL2Oracle serves the requests from the inner L2 oracle only if they match the recorded accesses.
+func NewReplayL2Oracle(inner L2Oracle, replay *AccessReplay) L2Oracle {
+	return &observingL2Oracle{inner: inner, before: replay.check, after: replay.checkValue}
+}
+
+func noCheck(AccessKind, common.Hash) error { return nil }
+
+func (l *AccessLog) observe(kind AccessKind, key common.Hash, value interface{}) error {
+	size, err := accessSize(value)
+	if err != nil {
+		return err
+	}
+	return l.record(kind, key, size)
+}
+
+func (r *AccessReplay) check(kind AccessKind, key common.Hash) error {
+	_, err := r.expect(kind, key)
+	return err
+}
+
+// checkValue checks the size of the value served for the access that was just checked.
+func (r *AccessReplay) checkValue(kind AccessKind, key common.Hash, value interface{}) error {
+	size, err := accessSize(value)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := checkSize(r.accesses[r.next-1], size); err != nil {
+		return r.fail(err)
+	}
+	return nil
+}
+
+type observingL1Oracle struct {
+	inner  L1Oracle
+	before func(kind AccessKind, key common.Hash) error
+	after  observer
+}
+
+func (o *observingL1Oracle) FetchL1Header(ctx context.Context, blockHash common.Hash) (*types.Header, error) {
+	if err := o.before(L1HeaderAccess, blockHash); err != nil {
+		return nil, err
+	}
+	h, err := o.inner.FetchL1Header(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return h, o.after(L1HeaderAccess, blockHash, h)
+}
+
+func (o *observingL1Oracle) FetchL1BlockTransactions(ctx context.Context, blockHash common.Hash) (types.Transactions, error) {
+	if err := o.before(L1TransactionsAccess, blockHash); err != nil {
+		return nil, err
+	}
+	txs, err := o.inner.FetchL1BlockTransactions(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return txs, o.after(L1TransactionsAccess, blockHash, txs)
+}
+
+func (o *observingL1Oracle) FetchL1BlockReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	if err := o.before(L1ReceiptsAccess, blockHash); err != nil {
+		return nil, err
+	}
+	receipts, err := o.inner.FetchL1BlockReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return receipts, o.after(L1ReceiptsAccess, blockHash, receipts)
+}
+
+type observingL2Oracle struct {
+	inner  L2Oracle
+	before func(kind AccessKind, key common.Hash) error
+	after  observer
+}
+
+func (o *observingL2Oracle) FetchL2MPTNode(ctx context.Context, nodeHash common.Hash) ([]byte, error) {
+	if err := o.before(L2NodeAccess, nodeHash); err != nil {
+		return nil, err
+	}
+	node, err := o.inner.FetchL2MPTNode(ctx, nodeHash)
+	if err != nil {
+		return nil, err
+	}
+	return node, o.after(L2NodeAccess, nodeHash, node)
+}
+
+func (o *observingL2Oracle) FetchL2Block(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	if err := o.before(L2BlockAccess, blockHash); err != nil {
+		return nil, err
+	}
+	block, err := o.inner.FetchL2Block(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return block, o.after(L2BlockAccess, blockHash, block)
+}
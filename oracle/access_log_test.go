@@ -0,0 +1,71 @@
+package oracle_test
+
+import (
+	"bytes"
+	"context"
+	"op-mordor/oracle"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+type nodeOracle map[common.Hash][]byte
+
+func (o nodeOracle) FetchL2MPTNode(ctx context.Context, nodeHash common.Hash) ([]byte, error) {
+	return o[nodeHash], nil
+}
+
+func (o nodeOracle) FetchL2Block(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	return types.NewBlockWithHeader(&types.Header{Number: common.Big1}), nil
+}
+
+func TestAccessLog(t *testing.T) {
+	ctx := context.Background()
+	a, b := common.Hash{1}, common.Hash{2}
+	inner := nodeOracle{a: {1, 2, 3}, b: {4}}
+
+	var buf bytes.Buffer
+	recording := oracle.NewRecordingL2Oracle(inner, oracle.NewAccessLog(&buf))
+	_, err := recording.FetchL2MPTNode(ctx, a)
+	require.NoError(t, err)
+	_, err = recording.FetchL2Block(ctx, b)
+	require.NoError(t, err)
+	_, err = recording.FetchL2MPTNode(ctx, b)
+	require.NoError(t, err)
+
+	accesses, err := oracle.ReadAccessLog(&buf)
+	require.NoError(t, err)
+	require.Len(t, accesses, 3)
+	require.Equal(t, oracle.Access{Kind: oracle.L2NodeAccess, Key: a, Size: 3}, accesses[0])
+	require.Equal(t, oracle.L2BlockAccess, accesses[1].Kind)
+
+	t.Run("same order", func(t *testing.T) {
+		replay := oracle.NewAccessReplay(accesses)
+		replaying := oracle.NewReplayL2Oracle(inner, replay)
+		_, err := replaying.FetchL2MPTNode(ctx, a)
+		require.NoError(t, err)
+		require.ErrorIs(t, replay.Done(), oracle.ErrUnexpectedAccess)
+		_, err = replaying.FetchL2Block(ctx, b)
+		require.NoError(t, err)
+		_, err = replaying.FetchL2MPTNode(ctx, b)
+		require.NoError(t, err)
+		require.NoError(t, replay.Done())
+		_, err = replaying.FetchL2MPTNode(ctx, a)
+		require.ErrorIs(t, err, oracle.ErrUnexpectedAccess)
+	})
+
+	t.Run("different order", func(t *testing.T) {
+		replaying := oracle.NewReplayL2Oracle(inner, oracle.NewAccessReplay(accesses))
+		_, err := replaying.FetchL2MPTNode(ctx, b)
+		require.ErrorIs(t, err, oracle.ErrUnexpectedAccess)
+	})
+
+	t.Run("different value", func(t *testing.T) {
+		changed := nodeOracle{a: {1, 2}}
+		replaying := oracle.NewReplayL2Oracle(changed, oracle.NewAccessReplay(accesses))
+		_, err := replaying.FetchL2MPTNode(ctx, a)
+		require.ErrorIs(t, err, oracle.ErrUnexpectedAccess)
+	})
+}
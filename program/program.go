@@ -0,0 +1,128 @@
+package program
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"op-mordor/derivation"
+	"op-mordor/l1"
+	"op-mordor/l2"
+	"op-mordor/oracle"
+
+	"github.com/ethereum-optimism/optimism/op-node/chaincfg"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//go:embed l2config.json
+var l2config []byte
+
+// Inputs are the claims a run starts from: the L1 head to derive from, and the agreed L2 head to derive on top of.
+type Inputs struct {
+	L1Head common.Hash
+	L2Head common.Hash
+
+	ChainConfig  *params.ChainConfig
+	RollupConfig *rollup.Config
+}
+
+// Options enables the optional features of the L2 engine. Nil fields are disabled.
+type Options struct {
+	Exporter    *l2.BlockExporter
+	Tracer      *l2.TxTracer
+	Verifier    *l2.ReferenceVerifier
+	OutputRoots l2.OutputRootSink
+	// Checkpoints persists the progress of Run, and makes Run resume from the latest checkpoint.
+	// It must be created for the L1 head and L2 head of the Run inputs.
+	Checkpoints *l2.Checkpointer
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	L2Head     eth.L2BlockRef
+	OutputRoot eth.Bytes32
+	// Engine is the L2 engine at the end of the run, to inspect or serve the derived state.
+	Engine *l2.L2Engine
+}
+
+// DefaultConfigs returns the L2 chain config and the Goerli rollup config.
+func DefaultConfigs() (*params.ChainConfig, *rollup.Config, error) {
+	var conf params.ChainConfig
+	if err := json.Unmarshal(l2config, &conf); err != nil {
+		return nil, nil, fmt.Errorf("invalid l2config json: %w", err)
+	}
+	cfg := chaincfg.Goerli
+	cfg.SeqWindowSize = 20
+	cfg.ChannelTimeout = 20
+	return &conf, &cfg, nil
+}
+
+// NewL2Engine creates an L2 engine on top of the given L2 block, with the enabled options.
+func NewL2Engine(ctx context.Context, logger log.Logger, conf *params.ChainConfig, cfg *rollup.Config, l2Hash common.Hash, l2Oracle oracle.L2Oracle, opts Options) (*l2.L2Engine, error) {
+	engine, err := l2.NewL2Engine(ctx, logger, conf, l2Hash, l2Oracle, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating L2: %w", err)
+	}
+	if opts.Exporter != nil {
+		engine.SetBlockExporter(opts.Exporter)
+	}
+	if opts.Tracer != nil {
+		engine.SetTxTracer(opts.Tracer)
+	}
+	if opts.Verifier != nil {
+		engine.SetReferenceVerifier(opts.Verifier)
+	}
+	if opts.OutputRoots != nil {
+		engine.SetOutputRootSink(opts.OutputRoots)
+	}
+	if opts.Checkpoints != nil {
+		engine.SetCheckpointer(opts.Checkpoints)
+	}
+	return engine, nil
+}
+
+// NewDerivation creates the L1 chain view at the L1 head, and the engine and derivation on top of the L2 head.
+func NewDerivation(ctx context.Context, logger log.Logger, inputs Inputs, l1Oracle oracle.L1Oracle, l2Oracle oracle.L2Oracle, opts Options) (*derivation.Derivation, *l2.L2Engine, error) {
+	l1Fetcher, err := l1.NewOracleBackedL1Chain(ctx, l1Oracle, inputs.L1Head)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating L1: %w", err)
+	}
+	engine, err := NewL2Engine(ctx, logger, inputs.ChainConfig, inputs.RollupConfig, inputs.L2Head, l2Oracle, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return derivation.NewDerivation(logger, inputs.RollupConfig, l1Fetcher, engine), engine, nil
+}
+
+// Run derives the L2 chain from all L1 data up to the L1 head, and returns the resulting L2 head and output root.
+// With checkpoints enabled, the derivation continues from the latest checkpoint of an earlier run with the same inputs.
+func Run(ctx context.Context, logger log.Logger, inputs Inputs, l1Oracle oracle.L1Oracle, l2Oracle oracle.L2Oracle, opts Options) (*Result, error) {
+	if opts.Checkpoints != nil {
+		cp, err := opts.Checkpoints.Latest()
+		if err != nil {
+			return nil, fmt.Errorf("reading checkpoint: %w", err)
+		}
+		if cp != nil {
+			logger.Info("Resuming from checkpoint", "l2Start", inputs.L2Head, "l2Head", cp.L2Head)
+			inputs.L2Head = cp.L2Head.Hash
+		}
+		l2Oracle = opts.Checkpoints.Oracle(l2Oracle)
+	}
+	d, engine, err := NewDerivation(ctx, logger, inputs, l1Oracle, l2Oracle, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := d.Run()
+	if err != nil {
+		return &Result{Engine: engine}, fmt.Errorf("derivation: %w", err)
+	}
+	head, err := engine.L2BlockRefByLabel(ctx, eth.Unsafe)
+	if err != nil {
+		return &Result{Engine: engine}, fmt.Errorf("l2 head: %w", err)
+	}
+	return &Result{L2Head: head, OutputRoot: out, Engine: engine}, nil
+}
@@ -0,0 +1,92 @@
+package rpcpool
+
+import (
+	"context"
+	"math/big"
+	"op-mordor/l1"
+	"op-mordor/l2"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// L1Client spreads the calls of the L1 loading oracle over several endpoints.
+type L1Client struct {
+	pool    *Pool
+	clients []l1.Client
+}
+
+var _ l1.Client = (*L1Client)(nil)
+
+// NewL1Client creates a client over the given endpoints, which must match the number of endpoints of the pool.
+func NewL1Client(pool *Pool, clients []l1.Client) *L1Client {
+	return &L1Client{pool: pool, clients: clients}
+}
+
+func (c *L1Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return call(ctx, c.pool, "eth_getBlockByHash", func(i int) (*types.Header, error) {
+		return c.clients[i].HeaderByHash(ctx, hash)
+	})
+}
+
+func (c *L1Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return call(ctx, c.pool, "eth_getBlockByHash", func(i int) (*types.Block, error) {
+		return c.clients[i].BlockByHash(ctx, hash)
+	})
+}
+
+func (c *L1Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return call(ctx, c.pool, "eth_getTransactionReceipt", func(i int) (*types.Receipt, error) {
+		return c.clients[i].TransactionReceipt(ctx, txHash)
+	})
+}
+
+// L2Client spreads the calls of the L2 loading oracle over several endpoints.
+type L2Client struct {
+	pool    *Pool
+	clients []l2.Client
+}
+
+var _ l2.Client = (*L2Client)(nil)
+
+// NewL2Client creates a client over the given endpoints, which must match the number of endpoints of the pool.
+func NewL2Client(pool *Pool, clients []l2.Client) *L2Client {
+	return &L2Client{pool: pool, clients: clients}
+}
+
+func (c *L2Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return call(ctx, c.pool, "eth_getBlockByHash", func(i int) (*types.Block, error) {
+		return c.clients[i].BlockByHash(ctx, hash)
+	})
+}
+
+func (c *L2Client) DbGet(ctx context.Context, key common.Hash) ([]byte, error) {
+	return call(ctx, c.pool, "debug_dbGet", func(i int) ([]byte, error) {
+		return c.clients[i].DbGet(ctx, key)
+	})
+}
+
+// L2ReferenceClient spreads the calls of the reference verifier and the withdrawal lookups over several endpoints.
+type L2ReferenceClient struct {
+	pool    *Pool
+	clients []l2.ReferenceClient
+}
+
+var _ l2.ReferenceClient = (*L2ReferenceClient)(nil)
+
+// NewL2ReferenceClient creates a client over the given endpoints, which must match the number of endpoints of the pool.
+func NewL2ReferenceClient(pool *Pool, clients []l2.ReferenceClient) *L2ReferenceClient {
+	return &L2ReferenceClient{pool: pool, clients: clients}
+}
+
+func (c *L2ReferenceClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return call(ctx, c.pool, "eth_getBlockByNumber", func(i int) (*types.Block, error) {
+		return c.clients[i].BlockByNumber(ctx, number)
+	})
+}
+
+func (c *L2ReferenceClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return call(ctx, c.pool, "eth_getTransactionReceipt", func(i int) (*types.Receipt, error) {
+		return c.clients[i].TransactionReceipt(ctx, txHash)
+	})
+}
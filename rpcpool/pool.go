@@ -0,0 +1,134 @@
+package rpcpool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Strategy selects the endpoint that serves a call first. Calls move on to the next endpoint on errors.
+type Strategy string
+
+const (
+	// Failover starts every call at the first endpoint, the others are only used when it fails.
+	Failover Strategy = "failover"
+	// RoundRobin starts every call at the endpoint after the one the previous call started at.
+	RoundRobin Strategy = "round-robin"
+)
+
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case Failover, RoundRobin:
+		return Strategy(s), nil
+	default:
+		return "", fmt.Errorf("unknown rpc strategy %q", s)
+	}
+}
+
+// DisagreementError is returned in paranoid mode when two endpoints return different results for the same call.
+type DisagreementError struct {
+	Method    string
+	Endpoints [2]int
+}
+
+func (e *DisagreementError) Error() string {
+	return fmt.Sprintf("endpoints %d and %d disagree on %s", e.Endpoints[0], e.Endpoints[1], e.Method)
+}
+
+// Pool spreads calls over the endpoints of a layer. Endpoints are identified by their index, so URLs with
+// credentials do not end up in the logs.
+type Pool struct {
+	logger    log.Logger
+	endpoints int
+	strategy  Strategy
+	// paranoid makes every call fetch from two endpoints and compare the results
+	paranoid bool
+	next     atomic.Uint64
+}
+
+// NewPool creates a pool over the given number of endpoints. Paranoid mode needs at least two endpoints.
+func NewPool(logger log.Logger, endpoints int, strategy Strategy, paranoid bool) (*Pool, error) {
+	if endpoints < 1 {
+		return nil, errors.New("no rpc endpoints")
+	}
+	if paranoid && endpoints < 2 {
+		return nil, errors.New("paranoid mode needs at least two rpc endpoints")
+	}
+	if _, err := ParseStrategy(string(strategy)); err != nil {
+		return nil, err
+	}
+	return &Pool{logger: logger, endpoints: endpoints, strategy: strategy, paranoid: paranoid}, nil
+}
+
+// order returns the endpoints in the order a call tries them.
+func (p *Pool) order() []int {
+	start := 0
+	if p.strategy == RoundRobin {
+		start = int((p.next.Add(1) - 1) % uint64(p.endpoints))
+	}
+	order := make([]int, p.endpoints)
+	for i := range order {
+		order[i] = (start + i) % p.endpoints
+	}
+	return order
+}
+
+// call calls fn with the endpoints in the order of the strategy until it succeeds. In paranoid mode it continues
+// until a second endpoint succeeds, and fails if the RLP encodings of the two results differ.
+func call[T any](ctx context.Context, p *Pool, method string, fn func(endpoint int) (T, error)) (T, error) {
+	var (
+		result T
+		first  = -1
+		err    error
+	)
+	for _, i := range p.order() {
+		if ctx.Err() != nil {
+			break
+		}
+		res, callErr := fn(i)
+		if callErr != nil {
+			p.logger.Warn("RPC call failed", "method", method, "endpoint", i, "err", callErr)
+			err = callErr
+			continue
+		}
+		if first < 0 {
+			result, first = res, i
+			if !p.paranoid {
+				return result, nil
+			}
+			continue
+		}
+		if err := sameResults(result, res); err != nil {
+			return result, fmt.Errorf("%w: %v", &DisagreementError{Method: method, Endpoints: [2]int{first, i}}, err)
+		}
+		return result, nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	if first >= 0 {
+		return result, fmt.Errorf("no endpoint to cross-check %s with, last error: %w", method, err)
+	}
+	return result, fmt.Errorf("%s failed on all endpoints, last error: %w", method, err)
+}
+
+// sameResults compares two results by their RLP encoding, which is their consensus representation.
+func sameResults(a, b interface{}) error {
+	encA, err := rlp.EncodeToBytes(a)
+	if err != nil {
+		return fmt.Errorf("encoding result: %w", err)
+	}
+	encB, err := rlp.EncodeToBytes(b)
+	if err != nil {
+		return fmt.Errorf("encoding result: %w", err)
+	}
+	if !bytes.Equal(encA, encB) {
+		return errors.New("results differ")
+	}
+	return nil
+}
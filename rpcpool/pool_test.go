@@ -0,0 +1,140 @@
+package rpcpool_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"op-mordor/l1"
+	"op-mordor/l2"
+	"op-mordor/rpcpool"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeL1Client serves a fixed header, or fails.
+type fakeL1Client struct {
+	header *types.Header
+	err    error
+	calls  int
+}
+
+func (f *fakeL1Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	f.calls++
+	return f.header, f.err
+}
+
+func (f *fakeL1Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	panic("not used")
+}
+
+func (f *fakeL1Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	panic("not used")
+}
+
+func newL1Client(t *testing.T, strategy rpcpool.Strategy, paranoid bool, fakes ...*fakeL1Client) *rpcpool.L1Client {
+	pool, err := rpcpool.NewPool(log.New(), len(fakes), strategy, paranoid)
+	require.NoError(t, err)
+	clients := make([]l1.Client, len(fakes))
+	for i, f := range fakes {
+		clients[i] = f
+	}
+	return rpcpool.NewL1Client(pool, clients)
+}
+
+func TestPool(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	header := testutils.RandomHeader(rng)
+	other := testutils.RandomHeader(rng)
+	failing := errors.New("provider down")
+	ctx := context.Background()
+
+	t.Run("failover", func(t *testing.T) {
+		a, b := &fakeL1Client{err: failing}, &fakeL1Client{header: header}
+		c := newL1Client(t, rpcpool.Failover, false, a, b)
+		for i := 0; i < 2; i++ {
+			h, err := c.HeaderByHash(ctx, header.Hash())
+			require.NoError(t, err)
+			require.Equal(t, header, h)
+		}
+		require.Equal(t, 2, a.calls)
+		require.Equal(t, 2, b.calls)
+	})
+
+	t.Run("round-robin", func(t *testing.T) {
+		a, b := &fakeL1Client{header: header}, &fakeL1Client{header: header}
+		c := newL1Client(t, rpcpool.RoundRobin, false, a, b)
+		for i := 0; i < 4; i++ {
+			_, err := c.HeaderByHash(ctx, header.Hash())
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, a.calls)
+		require.Equal(t, 2, b.calls)
+	})
+
+	t.Run("all-fail", func(t *testing.T) {
+		c := newL1Client(t, rpcpool.Failover, false, &fakeL1Client{err: failing}, &fakeL1Client{err: failing})
+		_, err := c.HeaderByHash(ctx, header.Hash())
+		require.ErrorIs(t, err, failing)
+	})
+
+	t.Run("paranoid/agree", func(t *testing.T) {
+		a, b := &fakeL1Client{header: header}, &fakeL1Client{header: header}
+		c := newL1Client(t, rpcpool.Failover, true, a, b)
+		h, err := c.HeaderByHash(ctx, header.Hash())
+		require.NoError(t, err)
+		require.Equal(t, header, h)
+		require.Equal(t, 1, b.calls)
+	})
+
+	t.Run("paranoid/disagree", func(t *testing.T) {
+		c := newL1Client(t, rpcpool.Failover, true, &fakeL1Client{header: header}, &fakeL1Client{header: other})
+		_, err := c.HeaderByHash(ctx, header.Hash())
+		var disagreement *rpcpool.DisagreementError
+		require.ErrorAs(t, err, &disagreement)
+		require.Equal(t, [2]int{0, 1}, disagreement.Endpoints)
+	})
+
+	t.Run("paranoid/no-second-endpoint", func(t *testing.T) {
+		c := newL1Client(t, rpcpool.Failover, true, &fakeL1Client{header: header}, &fakeL1Client{err: failing})
+		_, err := c.HeaderByHash(ctx, header.Hash())
+		require.ErrorIs(t, err, failing)
+	})
+
+	t.Run("paranoid/single-endpoint", func(t *testing.T) {
+		_, err := rpcpool.NewPool(log.New(), 1, rpcpool.Failover, true)
+		require.Error(t, err)
+	})
+}
+
+// fakeReferenceClient serves a fixed block for any number.
+type fakeReferenceClient struct {
+	block *types.Block
+}
+
+func (f *fakeReferenceClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return f.block, nil
+}
+
+func (f *fakeReferenceClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	panic("not used")
+}
+
+func TestReferenceClientParanoid(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	block, _ := testutils.RandomBlock(rng, 2)
+	other, _ := testutils.RandomBlock(rng, 2)
+	pool, err := rpcpool.NewPool(log.New(), 2, rpcpool.Failover, true)
+	require.NoError(t, err)
+	c := rpcpool.NewL2ReferenceClient(pool, []l2.ReferenceClient{&fakeReferenceClient{block: block}, &fakeReferenceClient{block: other}})
+
+	_, err = c.BlockByNumber(context.Background(), block.Number())
+	var disagreement *rpcpool.DisagreementError
+	require.ErrorAs(t, err, &disagreement)
+	require.Equal(t, "eth_getBlockByNumber", disagreement.Method)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"op-mordor/l2"
+	"op-mordor/oracle"
+	"op-mordor/program"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli"
+)
+
+func runCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	res, err := runProgram(c, cfg, logger)
+	if err != nil {
+		logger.Error("state fn crit err", "err", err)
+	} else {
+		fmt.Println(res.OutputRoot)
+		if path := c.String(outputProofFlag.Name); path != "" {
+			if err := writeOutputRootProof(res.Engine, path); err != nil {
+				logger.Error("output root proof err", "err", err)
+			}
+		}
+	}
+	if addr := c.String(debugRpcAddrFlag.Name); addr != "" && res != nil {
+		if err := serveDebugRPC(logger, res.Engine, addr); err != nil {
+			logger.Error("debug rpc err", "err", err)
+		}
+	}
+	return err
+}
+
+func prefetchCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	res, err := runProgram(c, cfg, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("Prefetched pre-images", "store", cfg.storePath, "l2Head", res.L2Head, "outputRoot", res.OutputRoot)
+	return nil
+}
+
+func replayCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	// a replay serves the pre-images, and the recorded accesses, from the store only, never from the RPCs
+	cfg.mode = modeStore
+	res, err := runProgram(c, cfg, logger)
+	if err != nil {
+		// the run may fail on a consequence of the mismatch, like a missing trie node, instead of the mismatch itself
+		if cfg.replay != nil && cfg.replay.Err() != nil {
+			return fmt.Errorf("%w, the run failed with: %v", cfg.replay.Err(), err)
+		}
+		return err
+	}
+	fmt.Println(res.OutputRoot)
+	if path := c.String(outputProofFlag.Name); path != "" {
+		if err := writeOutputRootProof(res.Engine, path); err != nil {
+			return err
+		}
+	}
+	if cfg.replay != nil {
+		// the output root proof is part of the recorded accesses too
+		return cfg.replay.Done()
+	}
+	return nil
+}
+
+func verifyCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	res, err := runProgram(c, cfg, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("All derived blocks match the L2 RPC", "l2Head", res.L2Head, "outputRoot", res.OutputRoot)
+	return nil
+}
+
+func serveEngineCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	res, err := runProgram(c, cfg, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("Derived L2 chain", "l2Head", res.L2Head, "outputRoot", res.OutputRoot)
+	return serveDebugRPC(logger, res.Engine, c.String(serveAddrFlag.Name))
+}
+
+// runProgram runs the program from the L1 and L2 head arguments. The result holds the engine if the derivation
+// itself failed, so the derived state can still be inspected.
+func runProgram(c *cli.Context, cfg *config, logger log.Logger) (*program.Result, error) {
+	if err := expectArgs(c, 2, 2); err != nil {
+		return nil, err
+	}
+	l1Hash, err := parseHash("l1 hash", c.Args().Get(0))
+	if err != nil {
+		return nil, err
+	}
+	l2Hash, err := parseHash("l2 hash", c.Args().Get(1))
+	if err != nil {
+		return nil, err
+	}
+	conf, rollupCfg, err := cfg.loadConfigs()
+	if err != nil {
+		return nil, err
+	}
+	l1Oracle, l2Oracle, err := cfg.setupOracles(logger)
+	if err != nil {
+		return nil, fmt.Errorf("setting up oracles: %w", err)
+	}
+	if cfg.recordAccessLog != "" {
+		f, err := os.Create(cfg.recordAccessLog)
+		if err != nil {
+			return nil, fmt.Errorf("creating access log: %w", err)
+		}
+		// the log stays open for the accesses of the command after the run, like the output root proof
+		cfg.files = append(cfg.files, f)
+		accessLog := oracle.NewAccessLog(f)
+		l1Oracle = oracle.NewRecordingL1Oracle(l1Oracle, accessLog)
+		l2Oracle = oracle.NewRecordingL2Oracle(l2Oracle, accessLog)
+	}
+	if cfg.replayAccessLog != "" {
+		accesses, err := readAccessLog(cfg.replayAccessLog)
+		if err != nil {
+			return nil, err
+		}
+		cfg.replay = oracle.NewAccessReplay(accesses)
+		l1Oracle = oracle.NewReplayL1Oracle(l1Oracle, cfg.replay)
+		l2Oracle = oracle.NewReplayL2Oracle(l2Oracle, cfg.replay)
+	}
+	opts, err := cfg.engineOptions(logger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.checkpointInterval > 0 {
+		dstore, err := cfg.openStore()
+		if err != nil {
+			return nil, err
+		}
+		opts.Checkpoints = l2.NewCheckpointer(dstore, l1Hash, l2Hash, cfg.checkpointInterval)
+	}
+	inputs := program.Inputs{L1Head: l1Hash, L2Head: l2Hash, ChainConfig: conf, RollupConfig: rollupCfg}
+	res, err := program.Run(context.Background(), logger, inputs, l1Oracle, l2Oracle, opts)
+	if err == nil && cfg.witness != nil {
+		err = cfg.exportWitness(logger)
+	}
+	return res, err
+}
+
+func readAccessLog(path string) ([]oracle.Access, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening access log: %w", err)
+	}
+	defer f.Close()
+	accesses, err := oracle.ReadAccessLog(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading access log: %w", err)
+	}
+	return accesses, nil
+}
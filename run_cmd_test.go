@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"op-mordor/oracle"
+	"op-mordor/program"
+	"op-mordor/store"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-bindings/predeploys"
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// genesisFixture is a store with an L1 block and an L2 genesis block on top of it, and the configs of the chain.
+// Deriving from the L1 block on top of the L2 genesis block does not derive any blocks, but goes through the
+// whole program: loading both heads, resetting the pipeline and computing the output root.
+type genesisFixture struct {
+	storePath    string
+	chainConfig  string
+	rollupConfig string
+	l1Head       common.Hash
+	l2Head       common.Hash
+}
+
+func newGenesisFixture(t *testing.T) *genesisFixture {
+	dir := t.TempDir()
+	f := &genesisFixture{
+		storePath:    filepath.Join(dir, "store"),
+		chainConfig:  filepath.Join(dir, "chain.json"),
+		rollupConfig: filepath.Join(dir, "rollup.json"),
+	}
+	s, err := store.NewDiskStore(f.storePath)
+	require.NoError(t, err)
+
+	l1Header := &types.Header{
+		Number:     common.Big0,
+		Time:       1000,
+		GasLimit:   30_000_000,
+		Difficulty: common.Big0,
+		BaseFee:    big.NewInt(7),
+		UncleHash:  types.EmptyUncleHash,
+		TxHash:     types.EmptyRootHash,
+		// the receipts are read for system config updates
+		ReceiptHash: types.EmptyRootHash,
+		Root:        types.EmptyRootHash,
+	}
+	f.l1Head = l1Header.Hash()
+	require.NoError(t, s.StoreHeader(f.l1Head, l1Header))
+
+	// the output root needs the storage trie of the message passer
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, err := state.New(types.EmptyRootHash, db, nil)
+	require.NoError(t, err)
+	statedb.SetNonce(predeploys.L2ToL1MessagePasserAddr, 1)
+	statedb.SetState(predeploys.L2ToL1MessagePasserAddr, common.Hash{1}, common.Hash{2})
+	root, err := statedb.Commit(false)
+	require.NoError(t, err)
+	require.NoError(t, db.TrieDB().Commit(root, false, nil))
+	it := db.DiskDB().NewIterator(nil, nil)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			require.NoError(t, s.StoreNode(common.BytesToHash(it.Key()), it.Value()))
+		}
+	}
+	it.Release()
+
+	conf, rollupCfg, err := program.DefaultConfigs()
+	require.NoError(t, err)
+	l2Genesis := types.NewBlockWithHeader(&types.Header{
+		Number:     common.Big0,
+		Time:       l1Header.Time,
+		GasLimit:   rollupCfg.Genesis.SystemConfig.GasLimit,
+		Difficulty: common.Big0,
+		BaseFee:    big.NewInt(params1Gwei),
+		UncleHash:  types.EmptyUncleHash,
+		TxHash:     types.EmptyRootHash,
+		Root:       root,
+	})
+	f.l2Head = l2Genesis.Hash()
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(l2Genesis))
+
+	rollupCfg.Genesis.L1 = eth.BlockID{Hash: f.l1Head, Number: l1Header.Number.Uint64()}
+	rollupCfg.Genesis.L2 = eth.BlockID{Hash: f.l2Head, Number: 0}
+	rollupCfg.Genesis.L2Time = l2Genesis.Time()
+	writeJSON(t, f.chainConfig, conf)
+	writeJSON(t, f.rollupConfig, rollupCfg)
+	return f
+}
+
+const params1Gwei = 1_000_000_000
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0666))
+}
+
+func (f *genesisFixture) run(t *testing.T, command string, flags ...string) error {
+	args := append([]string{"op-mordor", command,
+		"--store", f.storePath,
+		"--l2.chain-config", f.chainConfig,
+		"--rollup.config", f.rollupConfig,
+		"--log.level", "error",
+	}, flags...)
+	return newApp().Run(append(args, f.l1Head.Hex(), f.l2Head.Hex()))
+}
+
+func TestReplayAccessLog(t *testing.T) {
+	f := newGenesisFixture(t)
+	dir := t.TempDir()
+	recorded := filepath.Join(dir, "access.log")
+	proof := filepath.Join(dir, "proof.json")
+
+	require.NoError(t, f.run(t, "run", "--mode", modeStore, "--access-log.record", recorded, "--output-proof", proof))
+	accesses, err := readAccessLog(recorded)
+	require.NoError(t, err)
+	require.NotEmpty(t, accesses)
+
+	t.Run("same accesses", func(t *testing.T) {
+		require.NoError(t, f.run(t, "replay", "--access-log", recorded, "--output-proof", filepath.Join(dir, "replayed.json")))
+	})
+
+	t.Run("missing access", func(t *testing.T) {
+		truncated := filepath.Join(dir, "truncated.log")
+		writeAccessLog(t, truncated, accesses[:len(accesses)-1])
+		err := f.run(t, "replay", "--access-log", truncated, "--output-proof", filepath.Join(dir, "replayed.json"))
+		require.ErrorIs(t, err, oracle.ErrUnexpectedAccess)
+	})
+
+	t.Run("different order", func(t *testing.T) {
+		if len(accesses) < 2 {
+			t.Skip("too few accesses to reorder")
+		}
+		swapped := append([]oracle.Access(nil), accesses...)
+		swapped[0], swapped[1] = swapped[1], swapped[0]
+		reordered := filepath.Join(dir, "reordered.log")
+		writeAccessLog(t, reordered, swapped)
+		err := f.run(t, "replay", "--access-log", reordered)
+		require.ErrorIs(t, err, oracle.ErrUnexpectedAccess)
+	})
+}
+
+func writeAccessLog(t *testing.T, path string, accesses []oracle.Access) {
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+	enc := json.NewEncoder(out)
+	for i := range accesses {
+		require.NoError(t, enc.Encode(&accesses[i]))
+	}
+}
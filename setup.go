@@ -2,54 +2,503 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"op-mordor/l1"
 	"op-mordor/l2"
 	"op-mordor/oracle"
+	"op-mordor/program"
+	"op-mordor/rpcpool"
 	"op-mordor/store"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/ethereum-optimism/optimism/op-node/rollup"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/mattn/go-isatty"
+	"github.com/urfave/cli"
 )
 
-const dialTimeout = 5 * time.Second // TODO: flag or env
+// config is the command configuration, read from the flags, with the environment as fallback.
+type config struct {
+	l1RpcURLs    []string
+	l2RpcURLs    []string
+	rpcStrategy  rpcpool.Strategy
+	rpcParanoid  bool
+	storePath    string
+	preloadStore bool
+	compression  store.Compression
+	dialTimeout  time.Duration
+	logLevel     string
+	logFormat    string
+	chainConfig  string
+	rollupConfig string
+	mode         string
 
-var (
-	l1RpcURL  string
-	l2RpcURL  string
-	storePath = "/tmp/mordor"
-)
+	exportDir           string
+	traceDir            string
+	tracerKind          string
+	verifyReport        string
+	outputRootsPath     string
+	outputRootsInterval uint64
+	checkpointInterval  uint64
+	recordAccessLog     string
+	replayAccessLog     string
+	witnessDir          string
+
+	// witness tracks the pre-images used by the run, if a witness is exported
+	witness *store.Witness
+	// backend is the opened store, shared by everything the command sets up
+	backend store.Backend
+	// replay checks the oracle accesses against a recorded access log, once the run set it up
+	replay *oracle.AccessReplay
+	// files are the outputs opened for the command, closed with the store once the command is done
+	files []io.Closer
+}
+
+func newConfig(c *cli.Context) (*config, error) {
+	cfg := &config{
+		l1RpcURLs:    splitURLs(c.String(l1RpcFlag.Name)),
+		l2RpcURLs:    splitURLs(c.String(l2RpcFlag.Name)),
+		rpcParanoid:  c.Bool(rpcParanoidFlag.Name),
+		storePath:    c.String(storeFlag.Name),
+		preloadStore: c.Bool(preloadStoreFlag.Name),
+		dialTimeout:  c.Duration(dialTimeoutFlag.Name),
+		logLevel:     c.String(logLevelFlag.Name),
+		logFormat:    c.String(logFormatFlag.Name),
+		chainConfig:  c.String(chainConfigFlag.Name),
+		rollupConfig: c.String(rollupConfigFlag.Name),
+		mode:         c.String(modeFlag.Name),
+
+		exportDir:           c.String(exportDirFlag.Name),
+		traceDir:            c.String(traceDirFlag.Name),
+		tracerKind:          c.String(tracerFlag.Name),
+		verifyReport:        c.String(verifyReportFlag.Name),
+		outputRootsPath:     c.String(outputRootsFlag.Name),
+		outputRootsInterval: c.Uint64(outputRootsIntervalFlag.Name),
+		checkpointInterval:  c.Uint64(checkpointIntervalFlag.Name),
+		recordAccessLog:     c.String(recordAccessLogFlag.Name),
+		replayAccessLog:     c.String(replayAccessLogFlag.Name),
+		witnessDir:          c.String(witnessFlag.Name),
+	}
+	if name := c.String(storeCompressionFlag.Name); name != "" {
+		compression, err := store.ParseCompression(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.compression = compression
+	}
+	cfg.rpcStrategy = rpcpool.Failover
+	if name := c.String(rpcStrategyFlag.Name); name != "" {
+		strategy, err := rpcpool.ParseStrategy(name)
+		if err != nil {
+			return nil, err
+		}
+		cfg.rpcStrategy = strategy
+	}
+	if cfg.mode == "" {
+		// commands without a mode flag load from the RPCs
+		cfg.mode = modeRPC
+	}
+	if cfg.mode != modeRPC && cfg.mode != modeStore {
+		return nil, fmt.Errorf("unknown mode %q", cfg.mode)
+	}
+	if cfg.traceDir != "" && !l2.TracerKind(cfg.tracerKind).Valid() {
+		return nil, fmt.Errorf("unknown tracer %q", cfg.tracerKind)
+	}
+	if cfg.outputRootsPath != "" && cfg.outputRootsInterval == 0 {
+		return nil, errors.New("output roots interval must be at least 1")
+	}
+	if cfg.witnessDir != "" {
+		if cfg.checkpointInterval > 0 {
+			// a resumed run does not read the pre-images before the checkpoint
+			return nil, errors.New("a witness cannot be exported with checkpoints enabled")
+		}
+		cfg.witness = store.NewWitness()
+	}
+	if cfg.recordAccessLog != "" && cfg.checkpointInterval > 0 {
+		// the reads served from checkpoints do not pass the recording oracles
+		return nil, errors.New("an access log cannot be recorded with checkpoints enabled")
+	}
+	return cfg, nil
+}
 
-func setupEnv() {
-	l1RpcURL = os.Getenv("OP_L1_RPC")
-	l2RpcURL = os.Getenv("OP_L2_RPC")
-	if path := os.Getenv("OP_STORE_PATH"); path != "" {
-		storePath = path
+// splitURLs splits a comma-separated list of RPC endpoints.
+func splitURLs(s string) []string {
+	var urls []string
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// setupLogger creates the logger with the configured level and format, and makes it the root logger.
+func (cfg *config) setupLogger() (log.Logger, error) {
+	lvl, err := log.LvlFromString(cfg.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	var format log.Format
+	switch cfg.logFormat {
+	case "terminal":
+		format = log.TerminalFormat(isatty.IsTerminal(os.Stderr.Fd()))
+	case "logfmt":
+		format = log.LogfmtFormat()
+	case "json":
+		format = log.JSONFormat()
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.logFormat)
+	}
+	handler := log.LvlFilterHandler(lvl, log.StreamHandler(os.Stderr, format))
+	log.Root().SetHandler(handler)
+	logger := log.New()
+	logger.SetHandler(handler)
+	return logger, nil
+}
+
+// loadConfigs loads the L2 chain and rollup configs from the configured files, or the defaults.
+func (cfg *config) loadConfigs() (*params.ChainConfig, *rollup.Config, error) {
+	conf, rollupCfg, err := program.DefaultConfigs()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.chainConfig != "" {
+		conf = new(params.ChainConfig)
+		if err := readJSONFile(cfg.chainConfig, conf); err != nil {
+			return nil, nil, fmt.Errorf("loading chain config: %w", err)
+		}
+	}
+	if cfg.rollupConfig != "" {
+		rollupCfg = new(rollup.Config)
+		if err := readJSONFile(cfg.rollupConfig, rollupCfg); err != nil {
+			return nil, nil, fmt.Errorf("loading rollup config: %w", err)
+		}
+	}
+	return conf, rollupCfg, nil
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
 	}
+	return json.Unmarshal(data, v)
 }
 
-func setupRpcOracles(logger log.Logger) (oracle.L1Oracle, oracle.L2Oracle, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+// openStore opens the configured store once, and returns the same store on later calls.
+func (cfg *config) openStore() (store.Backend, error) {
+	if cfg.backend == nil {
+		backend, err := store.Open(cfg.storePath, cfg.compression)
+		if err != nil {
+			return nil, fmt.Errorf("opening store: %w", err)
+		}
+		if cfg.preloadStore {
+			preloaded, err := store.Preload(backend)
+			if err != nil {
+				backend.Close()
+				return nil, err
+			}
+			backend = preloaded
+		}
+		cfg.backend = backend
+	}
+	return cfg.backend, nil
+}
+
+// openStoreReadOnly opens the configured store for commands that only read it, so older store layouts are not migrated.
+func (cfg *config) openStoreReadOnly() (store.Backend, error) {
+	if cfg.backend == nil {
+		backend, err := store.OpenReadOnly(cfg.storePath)
+		if err != nil {
+			return nil, fmt.Errorf("opening store: %w", err)
+		}
+		cfg.backend = backend
+	}
+	return cfg.backend, nil
+}
+
+// close closes the files opened for the command, and the store, if it was opened.
+func (cfg *config) close() {
+	for i := len(cfg.files) - 1; i >= 0; i-- {
+		if err := cfg.files[i].Close(); err != nil {
+			log.Error("Failed to close file", "err", err)
+		}
+	}
+	cfg.files = nil
+	if cfg.backend != nil {
+		if err := cfg.backend.Close(); err != nil {
+			log.Error("Failed to close store", "err", err)
+		}
+		cfg.backend = nil
+	}
+}
+
+// setupOracles creates the oracles of the configured mode.
+func (cfg *config) setupOracles(logger log.Logger) (oracle.L1Oracle, oracle.L2Oracle, error) {
+	switch cfg.mode {
+	case modeRPC:
+		return cfg.setupRpcOracles(logger)
+	case modeStore:
+		return cfg.setupStoreOracles()
+	default:
+		return nil, nil, fmt.Errorf("unknown mode %q", cfg.mode)
+	}
+}
+
+// setupStoreOracles creates oracles that only read the pre-images of the store, without any RPC.
+func (cfg *config) setupStoreOracles() (oracle.L1Oracle, oracle.L2Oracle, error) {
+	dstore, err := cfg.openStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	var source store.Source = dstore
+	if cfg.witness != nil {
+		source = cfg.witness.Source(dstore)
+	}
+	return l1.NewStoreL1Oracle(source), l2.NewStoreL2Oracle(source), nil
+}
+
+func (cfg *config) setupRpcOracles(logger log.Logger) (oracle.L1Oracle, oracle.L2Oracle, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.dialTimeout)
 	defer cancel()
 
-	l1Client, err := ethclient.DialContext(ctx, l1RpcURL)
+	l1Client, err := cfg.dialL1(ctx, logger)
 	if err != nil {
-		return nil, nil, fmt.Errorf("dialing l1 rpc: %w", err)
+		return nil, nil, err
 	}
-	rpcClient, err := rpc.DialContext(ctx, l2RpcURL)
+	l2Client, err := cfg.dialL2(ctx, logger)
 	if err != nil {
-		return nil, nil, fmt.Errorf("dialing l2 rpc: %w", err)
+		return nil, nil, err
 	}
-	dstore, err := store.NewDiskStore(storePath)
+	dstore, err := cfg.openStore()
 	if err != nil {
-		return nil, nil, fmt.Errorf("creating disk store: %w", err)
+		return nil, nil, err
 	}
 
-	l1Oracle := l1.NewLoadingL1Chain(logger, l1Client, dstore)
-	l2Oracle := l2.NewLoadingL2Chain(logger, rpcClient, dstore, dstore)
+	var (
+		sstore store.Store  = dstore
+		source store.Source = dstore
+	)
+	if cfg.witness != nil {
+		sstore, source = cfg.witness.Store(dstore), cfg.witness.Source(dstore)
+	}
+	l1Oracle := l1.NewLoadingL1Chain(logger, l1Client, sstore, source)
+	l2Oracle := l2.NewLoadingL2Chain(logger, l2Client, sstore, source)
 	return l1Oracle, l2Oracle, nil
 }
+
+// dialL1 dials the L1 endpoints. Several endpoints, or a single one in paranoid mode, are spread over by a pool.
+func (cfg *config) dialL1(ctx context.Context, logger log.Logger) (l1.Client, error) {
+	clients := make([]l1.Client, len(cfg.l1RpcURLs))
+	for i, url := range cfg.l1RpcURLs {
+		client, err := ethclient.DialContext(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("dialing l1 rpc %d: %w", i, err)
+		}
+		clients[i] = client
+	}
+	if len(clients) == 1 && !cfg.rpcParanoid {
+		return clients[0], nil
+	}
+	pool, err := rpcpool.NewPool(logger.New("layer", "l1"), len(clients), cfg.rpcStrategy, cfg.rpcParanoid)
+	if err != nil {
+		return nil, fmt.Errorf("l1 rpc: %w", err)
+	}
+	return rpcpool.NewL1Client(pool, clients), nil
+}
+
+// dialL2 dials the L2 endpoints, like dialL1.
+func (cfg *config) dialL2(ctx context.Context, logger log.Logger) (l2.Client, error) {
+	clients := make([]l2.Client, len(cfg.l2RpcURLs))
+	for i, url := range cfg.l2RpcURLs {
+		client, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("dialing l2 rpc %d: %w", i, err)
+		}
+		clients[i] = l2.NewRPCClient(client)
+	}
+	if len(clients) == 1 && !cfg.rpcParanoid {
+		return clients[0], nil
+	}
+	pool, err := rpcpool.NewPool(logger.New("layer", "l2"), len(clients), cfg.rpcStrategy, cfg.rpcParanoid)
+	if err != nil {
+		return nil, fmt.Errorf("l2 rpc: %w", err)
+	}
+	return rpcpool.NewL2Client(pool, clients), nil
+}
+
+// engineOptions creates the debugging options of the L2 engine enabled by the flags.
+func (cfg *config) engineOptions(logger log.Logger) (program.Options, error) {
+	var opts program.Options
+	var err error
+	if cfg.exportDir != "" {
+		if opts.Exporter, err = l2.NewBlockExporter(cfg.exportDir); err != nil {
+			return opts, fmt.Errorf("creating block exporter: %w", err)
+		}
+	}
+	if cfg.traceDir != "" {
+		if opts.Tracer, err = l2.NewTxTracer(l2.TracerKind(cfg.tracerKind), cfg.traceDir); err != nil {
+			return opts, fmt.Errorf("creating tracer: %w", err)
+		}
+	}
+	if cfg.verifyReport != "" {
+		if opts.Verifier, err = cfg.setupReferenceVerifier(logger); err != nil {
+			return opts, fmt.Errorf("creating reference verifier: %w", err)
+		}
+	}
+	if cfg.outputRootsPath != "" {
+		w, err := cfg.openOutputRoots()
+		if err != nil {
+			return opts, err
+		}
+		cfg.files = append(cfg.files, w)
+		opts.OutputRoots = l2.NewOutputRootWriter(w, cfg.outputRootsInterval)
+	}
+	return opts, nil
+}
+
+// dialL2Reference dials the L2 endpoints for the canonical blocks and receipts, like dialL1.
+func (cfg *config) dialL2Reference(ctx context.Context, logger log.Logger) (l2.ReferenceClient, error) {
+	clients := make([]l2.ReferenceClient, len(cfg.l2RpcURLs))
+	for i, url := range cfg.l2RpcURLs {
+		client, err := ethclient.DialContext(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("dialing l2 rpc %d: %w", i, err)
+		}
+		clients[i] = client
+	}
+	if len(clients) == 1 && !cfg.rpcParanoid {
+		return clients[0], nil
+	}
+	pool, err := rpcpool.NewPool(logger.New("layer", "l2"), len(clients), cfg.rpcStrategy, cfg.rpcParanoid)
+	if err != nil {
+		return nil, fmt.Errorf("l2 rpc: %w", err)
+	}
+	return rpcpool.NewL2ReferenceClient(pool, clients), nil
+}
+
+// setupReferenceVerifier creates a verifier that compares the derived blocks with the canonical blocks of the L2 RPC.
+func (cfg *config) setupReferenceVerifier(logger log.Logger) (*l2.ReferenceVerifier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.dialTimeout)
+	defer cancel()
+
+	client, err := cfg.dialL2Reference(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	return l2.NewReferenceVerifier(logger, client, cfg.verifyReport), nil
+}
+
+// nopCloser is a writer that is not closed, like stdout.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// openOutputRoots opens the output roots stream, "-" writes to stdout, which is not closed.
+func (cfg *config) openOutputRoots() (io.WriteCloser, error) {
+	if cfg.outputRootsPath == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	f, err := os.Create(cfg.outputRootsPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating output roots file: %w", err)
+	}
+	return f, nil
+}
+
+// exportWitness copies the pre-images used by the run from the store into the witness directory.
+func (cfg *config) exportWitness(logger log.Logger) error {
+	src, err := cfg.openStore()
+	if err != nil {
+		return err
+	}
+	dst, err := store.Open(cfg.witnessDir, cfg.compression)
+	if err != nil {
+		return fmt.Errorf("creating witness store: %w", err)
+	}
+	defer dst.Close()
+	stats, err := cfg.witness.Export(src, dst)
+	if err != nil {
+		return fmt.Errorf("exporting witness: %w", err)
+	}
+	var total uint64
+	for _, kind := range store.WitnessKinds {
+		s := stats[kind]
+		total += s.Bytes
+		logger.Info("Exported witness pre-images", "kind", kind, "count", s.Count, "bytes", s.Bytes)
+	}
+	logger.Info("Exported witness", "dir", cfg.witnessDir, "keys", cfg.witness.Len(), "bytes", total)
+	return nil
+}
+
+// writeOutputRootProof writes the output root proof artifact of the L2 head.
+func writeOutputRootProof(engine *l2.L2Engine, path string) error {
+	proof, err := engine.HeadOutputRootProof()
+	if err != nil {
+		return fmt.Errorf("computing output root proof: %w", err)
+	}
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding output root proof: %w", err)
+	}
+	return os.WriteFile(path, data, 0666)
+}
+
+// serveDebugRPC serves the read-only eth_ debug API over the L2 engine until the process is interrupted.
+func serveDebugRPC(logger log.Logger, engine *l2.L2Engine, addr string) error {
+	srv, err := l2.NewDebugRPCServer(engine)
+	if err != nil {
+		return err
+	}
+	return serveRPC(logger, srv, "debug", addr)
+}
+
+// serveRPC serves the JSON-RPC server over HTTP until the process is interrupted.
+func serveRPC(logger log.Logger, srv *rpc.Server, name string, addr string) error {
+	defer srv.Stop()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s rpc addr: %w", name, err)
+	}
+	httpSrv := &http.Server{Handler: srv}
+	go func() {
+		if err := httpSrv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(name+" rpc server failed", "err", err)
+		}
+	}()
+	logger.Info("Serving "+name+" RPC", "addr", listener.Addr())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+	return httpSrv.Close()
+}
+
+// setupL2Engine creates the L2 engine on top of the given L2 block, with the debugging options enabled by the flags.
+func (cfg *config) setupL2Engine(ctx context.Context, logger log.Logger, l2Hash common.Hash, l2Oracle oracle.L2Oracle) (*l2.L2Engine, error) {
+	conf, rollupCfg, err := cfg.loadConfigs()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := cfg.engineOptions(logger)
+	if err != nil {
+		return nil, err
+	}
+	return program.NewL2Engine(ctx, logger, conf, rollupCfg, l2Hash, l2Oracle, opts)
+}
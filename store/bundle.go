@@ -0,0 +1,308 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// A bundle is a single-file archive of pre-images, laid out as:
+//
+//	header: magic (8 bytes) | version (uint32) | compression (uint8) | reserved (3 bytes) | count (uint64) | index offset (uint64) | index checksum (32 bytes)
+//	values: the (compressed) values, back to back
+//	index:  count entries of key (32 bytes) | value offset (uint64) | value length (uint32) | value checksum (uint32), sorted by key
+//
+// All integers are big-endian. Values are compressed individually, so any value can be read without reading others.
+// The index checksum is the keccak256 hash of the index, and is verified on open. The value checksum is the CRC-32C
+// of the stored value, and is verified on every read.
+const (
+	bundleVersion     = 2
+	bundleHeaderSize  = 8 + 4 + 1 + 3 + 8 + 8 + common.HashLength
+	bundleEntrySize   = common.HashLength + 8 + 4 + 4
+	bundleMaxValueLen = 1<<32 - 1
+)
+
+var (
+	bundleMagic    = [8]byte{'M', 'O', 'R', 'D', 'O', 'R', 'P', 'B'}
+	bundleCRCTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type bundleEntry struct {
+	key      common.Hash
+	offset   uint64
+	length   uint32
+	checksum uint32
+}
+
+// BundleWriter writes pre-images into a new bundle. The bundle is written to a temporary file,
+// and only appears at its path once Close succeeds. Abort discards the bundle instead.
+type BundleWriter struct {
+	path        string
+	f           *os.File
+	w           *bufio.Writer
+	compression Compression
+	offset      uint64
+	entries     []bundleEntry
+	seen        map[common.Hash]struct{}
+}
+
+var _ Store = (*BundleWriter)(nil)
+
+func NewBundleWriter(path string, compression Compression) (*BundleWriter, error) {
+	if !compression.valid() {
+		return nil, fmt.Errorf("unknown bundle compression %d", compression)
+	}
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating bundle: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	// the header is written on close, once the index offset is known
+	if _, err := w.Write(make([]byte, bundleHeaderSize)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("writing bundle header: %w", err)
+	}
+	return &BundleWriter{
+		path:        path,
+		f:           f,
+		w:           w,
+		compression: compression,
+		offset:      bundleHeaderSize,
+		seen:        make(map[common.Hash]struct{}),
+	}, nil
+}
+
+func (b *BundleWriter) StoreHeader(hash common.Hash, header *types.Header) error {
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return fmt.Errorf("encoding header: %w", err)
+	}
+	return b.StoreNode(hash, data)
+}
+
+func (b *BundleWriter) StoreTransactions(txRoot common.Hash, transactions types.Transactions) error {
+	return storeTransactions(keyValueWriter{s: b}, txRoot, transactions)
+}
+
+func (b *BundleWriter) StoreReceipts(receipts types.Receipts) error {
+	return storeReceipts(keyValueWriter{s: b}, receipts)
+}
+
+// StoreNode adds the value to the bundle. Values of keys that were already added are skipped.
+func (b *BundleWriter) StoreNode(nodeHash common.Hash, node []byte) error {
+	if _, ok := b.seen[nodeHash]; ok {
+		return nil
+	}
+	value := b.compression.encode(node)
+	if len(value) > bundleMaxValueLen {
+		return fmt.Errorf("value of %s is too large for a bundle: %d bytes", nodeHash, len(value))
+	}
+	if _, err := b.w.Write(value); err != nil {
+		return fmt.Errorf("writing value of %s: %w", nodeHash, err)
+	}
+	b.entries = append(b.entries, bundleEntry{
+		key:      nodeHash,
+		offset:   b.offset,
+		length:   uint32(len(value)),
+		checksum: crc32.Checksum(value, bundleCRCTable),
+	})
+	b.seen[nodeHash] = struct{}{}
+	b.offset += uint64(len(value))
+	return nil
+}
+
+// Close writes the index and header, and moves the bundle into place.
+func (b *BundleWriter) Close() error {
+	if err := b.finish(); err != nil {
+		b.f.Close()
+		os.Remove(b.f.Name())
+		return err
+	}
+	if err := b.f.Close(); err != nil {
+		return fmt.Errorf("closing bundle: %w", err)
+	}
+	return os.Rename(b.f.Name(), b.path)
+}
+
+// Abort discards the bundle, so nothing appears at its path.
+func (b *BundleWriter) Abort() error {
+	b.f.Close()
+	if err := os.Remove(b.f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing bundle: %w", err)
+	}
+	return nil
+}
+
+func (b *BundleWriter) finish() error {
+	sort.Slice(b.entries, func(i, j int) bool {
+		return bytes.Compare(b.entries[i].key[:], b.entries[j].key[:]) < 0
+	})
+	hasher := crypto.NewKeccakState()
+	var buf [bundleEntrySize]byte
+	for _, e := range b.entries {
+		copy(buf[:], e.key[:])
+		binary.BigEndian.PutUint64(buf[common.HashLength:], e.offset)
+		binary.BigEndian.PutUint32(buf[common.HashLength+8:], e.length)
+		binary.BigEndian.PutUint32(buf[common.HashLength+12:], e.checksum)
+		hasher.Write(buf[:])
+		if _, err := b.w.Write(buf[:]); err != nil {
+			return fmt.Errorf("writing bundle index: %w", err)
+		}
+	}
+	if err := b.w.Flush(); err != nil {
+		return fmt.Errorf("writing bundle: %w", err)
+	}
+	var header [bundleHeaderSize]byte
+	copy(header[:8], bundleMagic[:])
+	binary.BigEndian.PutUint32(header[8:], bundleVersion)
+	header[12] = byte(b.compression)
+	binary.BigEndian.PutUint64(header[16:], uint64(len(b.entries)))
+	binary.BigEndian.PutUint64(header[24:], b.offset)
+	hasher.Read(header[32:])
+	if _, err := b.f.WriteAt(header[:], 0); err != nil {
+		return fmt.Errorf("writing bundle header: %w", err)
+	}
+	return b.f.Sync()
+}
+
+// Bundle reads pre-images from a bundle. The index is loaded into memory, values are read on demand.
+type Bundle struct {
+	f           *os.File
+	compression Compression
+	entries     []bundleEntry
+}
+
+var _ Source = (*Bundle)(nil)
+
+// OpenBundle opens the bundle at the path and loads its index.
+func OpenBundle(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening bundle: %w", err)
+	}
+	b, err := readBundleIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading bundle %s: %w", path, err)
+	}
+	return b, nil
+}
+
+func readBundleIndex(f *os.File) (*Bundle, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var header [bundleHeaderSize]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if !bytes.Equal(header[:8], bundleMagic[:]) {
+		return nil, errors.New("not a pre-image bundle")
+	}
+	if v := binary.BigEndian.Uint32(header[8:]); v != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", v)
+	}
+	compression := Compression(header[12])
+	if !compression.valid() {
+		return nil, fmt.Errorf("unknown bundle compression %d", compression)
+	}
+	count := binary.BigEndian.Uint64(header[16:])
+	indexOffset := binary.BigEndian.Uint64(header[24:])
+	if indexOffset < bundleHeaderSize || indexOffset > uint64(info.Size()) ||
+		(uint64(info.Size())-indexOffset)/bundleEntrySize != count || (uint64(info.Size())-indexOffset)%bundleEntrySize != 0 {
+		return nil, errors.New("index does not match the bundle size")
+	}
+	index := make([]byte, count*bundleEntrySize)
+	if _, err := f.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	if checksum := crypto.Keccak256Hash(index); !bytes.Equal(checksum[:], header[32:]) {
+		return nil, fmt.Errorf("index checksum mismatch: %s, header has %x", checksum, header[32:])
+	}
+	entries := make([]bundleEntry, count)
+	for i := range entries {
+		data := index[i*bundleEntrySize:]
+		e := bundleEntry{
+			key:      common.BytesToHash(data[:common.HashLength]),
+			offset:   binary.BigEndian.Uint64(data[common.HashLength:]),
+			length:   binary.BigEndian.Uint32(data[common.HashLength+8:]),
+			checksum: binary.BigEndian.Uint32(data[common.HashLength+12:]),
+		}
+		if e.offset < bundleHeaderSize || e.offset+uint64(e.length) > indexOffset {
+			return nil, fmt.Errorf("value of %s is out of bounds", e.key)
+		}
+		if i > 0 && bytes.Compare(entries[i-1].key[:], e.key[:]) >= 0 {
+			return nil, fmt.Errorf("index is not sorted at %s", e.key)
+		}
+		entries[i] = e
+	}
+	return &Bundle{f: f, compression: compression, entries: entries}, nil
+}
+
+// Keys returns the keys of all pre-images in the bundle, in order.
+func (b *Bundle) Keys() []common.Hash {
+	keys := make([]common.Hash, len(b.entries))
+	for i, e := range b.entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+func (b *Bundle) ReadHeader(hash common.Hash) (*types.Header, error) {
+	data, err := b.ReadNode(hash)
+	if err != nil {
+		return nil, err
+	}
+	var header types.Header
+	if err := rlp.DecodeBytes(data, &header); err != nil {
+		return nil, fmt.Errorf("decoding header %s: %w", hash, err)
+	}
+	return &header, nil
+}
+
+func (b *Bundle) ReadTransactions(txRoot common.Hash) (types.Transactions, error) {
+	return readTransactions(b, txRoot)
+}
+
+func (b *Bundle) ReadReceipts(receiptRoot common.Hash) (types.Receipts, error) {
+	return readReceipts(b, receiptRoot)
+}
+
+func (b *Bundle) ReadNode(nodeHash common.Hash) ([]byte, error) {
+	i := sort.Search(len(b.entries), func(i int) bool {
+		return bytes.Compare(b.entries[i].key[:], nodeHash[:]) >= 0
+	})
+	if i == len(b.entries) || b.entries[i].key != nodeHash {
+		return nil, NoDataError{nodeHash}
+	}
+	e := b.entries[i]
+	value := make([]byte, e.length)
+	if _, err := b.f.ReadAt(value, int64(e.offset)); err != nil {
+		return nil, fmt.Errorf("reading value of %s: %w", nodeHash, err)
+	}
+	if crc32.Checksum(value, bundleCRCTable) != e.checksum {
+		return nil, fmt.Errorf("checksum mismatch of value of %s", nodeHash)
+	}
+	decoded, err := b.compression.decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing value of %s: %w", nodeHash, err)
+	}
+	return decoded, nil
+}
+
+func (b *Bundle) Close() error {
+	return b.f.Close()
+}
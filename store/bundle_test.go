@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"math/rand"
+	"op-mordor/store"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	for _, compression := range []store.Compression{store.NoCompression, store.SnappyCompression} {
+		rng := rand.New(rand.NewSource(420))
+		path := filepath.Join(t.TempDir(), "test.bundle")
+
+		w, err := store.NewBundleWriter(path, compression)
+		require.NoError(t, err)
+		block, _ := testutils.RandomBlock(rng, 16)
+		require.NoError(t, store.BlockStore{Store: w}.StoreBlock(block))
+		nodes := make(map[common.Hash][]byte)
+		for i := 0; i < 10; i++ {
+			key := testutils.RandomHash(rng)
+			nodes[key] = testutils.RandomData(rng, 1+i*100)
+			require.NoError(t, w.StoreNode(key, nodes[key]))
+		}
+		require.NoError(t, w.Close())
+
+		b, err := store.OpenBundle(path)
+		require.NoError(t, err)
+		defer b.Close()
+
+		for key, node := range nodes {
+			value, err := b.ReadNode(key)
+			require.NoError(t, err)
+			require.Equal(t, node, value)
+		}
+		_, err = b.ReadNode(testutils.RandomHash(rng))
+		requireNoDataError(t, err)
+
+		read, err := store.BlockSource{Source: b}.ReadBlock(block.Hash())
+		require.NoError(t, err)
+		require.Equal(t, block.Hash(), read.Hash())
+		require.Len(t, read.Transactions(), len(block.Transactions()))
+	}
+}
+
+func TestBundleAbort(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	path := filepath.Join(t.TempDir(), "test.bundle")
+
+	w, err := store.NewBundleWriter(path, store.NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, w.StoreNode(testutils.RandomHash(rng), testutils.RandomData(rng, 100)))
+	require.NoError(t, w.Abort())
+	require.NoFileExists(t, path)
+	require.NoFileExists(t, path+".tmp")
+}
+
+func TestBundleCorrupted(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	path := filepath.Join(t.TempDir(), "test.bundle")
+
+	w, err := store.NewBundleWriter(path, store.NoCompression)
+	require.NoError(t, err)
+	key := testutils.RandomHash(rng)
+	require.NoError(t, w.StoreNode(key, testutils.RandomData(rng, 100)))
+	require.NoError(t, w.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	corrupt := func(t *testing.T, offset int) string {
+		corrupted := filepath.Join(t.TempDir(), "corrupted.bundle")
+		modified := append([]byte(nil), data...)
+		modified[offset] ^= 0xff
+		require.NoError(t, os.WriteFile(corrupted, modified, 0o644))
+		return corrupted
+	}
+
+	t.Run("index", func(t *testing.T) {
+		// the last byte of the index is part of the value checksum of the only entry
+		_, err := store.OpenBundle(corrupt(t, len(data)-1))
+		require.ErrorContains(t, err, "index checksum mismatch")
+	})
+
+	t.Run("value", func(t *testing.T) {
+		// the value directly precedes the index of one 56 byte entry
+		b, err := store.OpenBundle(corrupt(t, len(data)-56-1))
+		require.NoError(t, err)
+		defer b.Close()
+		_, err = b.ReadNode(key)
+		require.ErrorContains(t, err, "checksum mismatch")
+	})
+}
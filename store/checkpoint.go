@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CheckpointStore persists the progress of runs next to the pre-images the runs need to resume.
+// Checkpoints are opaque to the store, and identified by the hash of the run inputs.
+type CheckpointStore interface {
+	StoreCheckpoint(id common.Hash, checkpoint []byte) error
+
+	// ReadCheckpoint returns a NoDataError if there is no checkpoint with the given id.
+	ReadCheckpoint(id common.Hash) ([]byte, error)
+}
+
+const checkpointDir = "checkpoints"
+
+func (s DiskStore) StoreCheckpoint(id common.Hash, checkpoint []byte) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	// write the new checkpoint next to the old one, so a crash never leaves a partial checkpoint
+	err := writeFileAtomic(filepath.Join(s.dir, checkpointDir, id.Hex()), func(w io.Writer) error {
+		_, err := w.Write(checkpoint)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s DiskStore) ReadCheckpoint(id common.Hash) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, checkpointDir, id.Hex()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NoDataError{id}
+	} else if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	return data, nil
+}
+
+// Checkpoints returns the ids of all stored checkpoints.
+func (s DiskStore) Checkpoints() ([]common.Hash, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, checkpointDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing checkpoints: %w", err)
+	}
+	return fileKeys(entries), nil
+}
+
+func (s DiskStore) DeleteCheckpoint(id common.Hash) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := os.Remove(filepath.Join(s.dir, checkpointDir, id.Hex())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing checkpoint %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// Compression is the compression of stored values.
+type Compression uint8
+
+const (
+	NoCompression     Compression = 0
+	SnappyCompression Compression = 1
+)
+
+// ParseCompression parses "none" or "snappy".
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "none":
+		return NoCompression, nil
+	case "snappy":
+		return SnappyCompression, nil
+	default:
+		return 0, fmt.Errorf("unknown compression %q", name)
+	}
+}
+
+func (c Compression) valid() bool {
+	return c == NoCompression || c == SnappyCompression
+}
+
+func (c Compression) encode(value []byte) []byte {
+	if c == SnappyCompression {
+		return snappy.Encode(nil, value)
+	}
+	return value
+}
+
+func (c Compression) decode(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case SnappyCompression:
+		return snappy.Decode(nil, data)
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c)
+	}
+}
+
+// encodeRecord prefixes the compressed value with a header byte that holds the compression,
+// so records of any compression can be read back.
+func encodeRecord(c Compression, value []byte) []byte {
+	return append([]byte{byte(c)}, c.encode(value)...)
+}
+
+// decodeRecord returns the decompressed value of a record.
+func decodeRecord(record []byte) ([]byte, error) {
+	if len(record) == 0 {
+		return nil, errors.New("empty record")
+	}
+	value, err := Compression(record[0]).decode(record[1:])
+	if err != nil {
+		return nil, fmt.Errorf("decoding record: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// diskLayoutVersion is the version of the DiskStore layout:
+//
+//	1: all pre-images in the store dir, without a layout marker
+//	2: pre-images in shard dirs by the first byte of the hash, files written atomically
+//	3: every file is a record with a header byte that holds the compression of the pre-image
+const diskLayoutVersion = 3
+
+// layoutFile holds the layout version of a DiskStore.
+const layoutFile = "LAYOUT"
+
+// tmpFilePattern names the temporary files of atomic writes. These never parse as a key.
+const tmpFilePattern = ".tmp-*"
+
+// readLayout returns the layout version of the store in the dir. Stores without a layout marker have version 1.
+func readLayout(dir string) (int, error) {
+	version := 1
+	data, err := os.ReadFile(filepath.Join(dir, layoutFile))
+	if err == nil {
+		if version, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return 0, fmt.Errorf("invalid store layout %q: %w", data, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("reading store layout: %w", err)
+	}
+	if version > diskLayoutVersion || version < 1 {
+		return 0, fmt.Errorf("unsupported store layout version %d, expected %d", version, diskLayoutVersion)
+	}
+	return version, nil
+}
+
+// checkLayout checks the layout version of the store in the dir, and migrates stores with an older layout.
+func checkLayout(dir string) error {
+	version, err := readLayout(dir)
+	if err != nil {
+		return err
+	}
+	if version == diskLayoutVersion {
+		return nil
+	}
+	if version < 2 {
+		if err := migrateFlatLayout(dir); err != nil {
+			return fmt.Errorf("migrating store to layout version 2: %w", err)
+		}
+	}
+	if version < 3 {
+		if err := migrateRecords(dir); err != nil {
+			return fmt.Errorf("migrating store to layout version 3: %w", err)
+		}
+	}
+	return writeFileAtomic(filepath.Join(dir, layoutFile), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%d\n", diskLayoutVersion)
+		return err
+	})
+}
+
+// migrateFlatLayout moves the pre-images of a version 1 store into their shard dirs.
+// A new, empty store has nothing to migrate.
+func migrateFlatLayout(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("listing storage dir: %w", err)
+	}
+	for _, e := range entries {
+		var key common.Hash
+		if e.IsDir() || key.UnmarshalText([]byte(e.Name())) != nil {
+			continue
+		}
+		shard := filepath.Join(dir, shardName(key))
+		if err := os.MkdirAll(shard, 0777); err != nil {
+			return fmt.Errorf("creating shard dir: %w", err)
+		}
+		if err := os.Rename(filepath.Join(dir, e.Name()), filepath.Join(shard, key.Hex())); err != nil {
+			return fmt.Errorf("moving %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// migrateRecords rewrites the pre-images of a version 2 store as uncompressed records.
+// Records that already hold the pre-image of their key are skipped, so an interrupted migration can be resumed.
+// Any other file is corrupt, and is wrapped in a record as is, so it is still reported as a mismatch.
+func migrateRecords(dir string) error {
+	keys, err := DiskStore{dir: dir, layout: 2}.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		path := filepath.Join(dir, shardName(key), key.Hex())
+		value, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		if crypto.Keccak256Hash(value) != key {
+			if migrated, err := decodeRecord(value); err == nil && crypto.Keccak256Hash(migrated) == key {
+				continue
+			}
+		}
+		if err := writeFileAtomic(path, func(w io.Writer) error {
+			_, err := w.Write(encodeRecord(NoCompression, value))
+			return err
+		}); err != nil {
+			return fmt.Errorf("rewriting %s: %w", key, err)
+		}
+	}
+	return nil
+}
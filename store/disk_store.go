@@ -2,29 +2,67 @@ package store
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
-	"github.com/ethereum/go-ethereum/trie"
 )
 
+// DiskStore stores every pre-image in a file named by its hash, in a shard directory named by the first byte of the hash.
+// Files are written atomically and never rewritten, since the content of a pre-image is fixed by its hash.
+// Every file is a record that starts with the compression of the pre-image, so stores may mix compressions.
 type DiskStore struct {
-	dir string
+	dir         string
+	compression Compression
+	// layout is the layout version of the files, older than diskLayoutVersion only for read-only stores
+	layout   int
+	readOnly bool
 }
 
+// ErrReadOnly is returned by the writes to a store that is opened read-only.
+var ErrReadOnly = errors.New("store is opened read-only")
+
+// NewDiskStore opens the store in the directory, creating it if needed, and migrates stores with an older layout.
+// New pre-images are stored uncompressed.
 func NewDiskStore(dir string) (*DiskStore, error) {
-	err := os.Mkdir(dir, 0777)
-	if err != nil && os.IsNotExist(err) {
+	return NewDiskStoreWithCompression(dir, NoCompression)
+}
+
+// NewDiskStoreWithCompression opens the store like NewDiskStore, and compresses new pre-images.
+func NewDiskStoreWithCompression(dir string, compression Compression) (*DiskStore, error) {
+	if !compression.valid() {
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
 		return nil, fmt.Errorf("create storage dir: %w", err)
 	}
-	return &DiskStore{dir: dir}, nil
+	if err := checkLayout(dir); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir, compression: compression, layout: diskLayoutVersion}, nil
+}
+
+// OpenDiskStoreReadOnly opens the existing store in the directory without writing to it.
+// Stores with an older layout are read as they are, and are not migrated.
+func OpenDiskStoreReadOnly(dir string) (*DiskStore, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("opening storage dir: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("storage path %s is not a directory", dir)
+	}
+	layout, err := readLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir, layout: layout, readOnly: true}, nil
 }
 
 func (s DiskStore) StoreHeader(hash common.Hash, header *types.Header) error {
@@ -34,30 +72,11 @@ func (s DiskStore) StoreHeader(hash common.Hash, header *types.Header) error {
 }
 
 func (s DiskStore) StoreTransactions(txRoot common.Hash, txs types.Transactions) error {
-	pkw := keyValueWriter{s: s}
-	hasher := &noResetTrie{*trie.NewStackTrie(pkw)}
-
-	testTxHash := types.DeriveSha(txs, hasher)
-	if testTxHash != txRoot {
-		return fmt.Errorf("expected txRoot %s does not match actual root %s", txRoot, testTxHash)
-	}
-	_, err := hasher.Commit()
-	if err != nil {
-		return fmt.Errorf("store tx: %w", err)
-	}
-	return nil
+	return storeTransactions(keyValueWriter{s: s}, txRoot, txs)
 }
 
 func (s DiskStore) StoreReceipts(receipts types.Receipts) error {
-	pkw := keyValueWriter{s: s}
-	hasher := &noResetTrie{*trie.NewStackTrie(pkw)}
-
-	types.DeriveSha(receipts, hasher)
-	_, err := hasher.Commit()
-	if err != nil {
-		return fmt.Errorf("store receipts: %w", err)
-	}
-	return nil
+	return storeReceipts(keyValueWriter{s: s}, receipts)
 }
 
 func (s DiskStore) StoreNode(nodeHash common.Hash, node []byte) error {
@@ -70,20 +89,66 @@ func (s DiskStore) StoreNode(nodeHash common.Hash, node []byte) error {
 type dataSource func(w io.Writer) error
 
 func (s DiskStore) store(hash common.Hash, source dataSource) error {
-	f, err := os.Create(s.fileName(hash))
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	path := s.fileName(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking file: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := source(&buf); err != nil {
+		return fmt.Errorf("encoding data: %w", err)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(encodeRecord(s.compression, buf.Bytes()))
+		return err
+	})
+}
+
+// writeFileAtomic writes the file next to its destination, syncs it and moves it into place,
+// so a crash never leaves a partially written file at the path.
+func writeFileAtomic(path string, source dataSource) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("creating dir: %w", err)
+	}
+	f, err := os.CreateTemp(dir, tmpFilePattern)
 	if err != nil {
 		return fmt.Errorf("creating file: %w", err)
 	}
-	defer f.Close()
-	err = source(f)
-	if err != nil {
+	if err := source(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
 		return fmt.Errorf("writing data: %w", err)
 	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("syncing file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("closing file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("moving file into place: %w", err)
+	}
 	return nil
 }
 
 func (s DiskStore) fileName(hash common.Hash) string {
-	return fmt.Sprintf("%s/%s", s.dir, hash.Hex())
+	if s.layout == 1 {
+		return filepath.Join(s.dir, hash.Hex())
+	}
+	return filepath.Join(s.dir, shardName(hash), hash.Hex())
+}
+
+func shardName(hash common.Hash) string {
+	return hex.EncodeToString(hash[:1])
 }
 
 type NoDataError struct {
@@ -110,11 +175,11 @@ func (s DiskStore) ReadHeader(hash common.Hash) (*types.Header, error) {
 }
 
 func (s DiskStore) ReadTransactions(txRoot common.Hash) (types.Transactions, error) {
-	panic("implement")
+	return readTransactions(s, txRoot)
 }
 
-func (s DiskStore) ReadReceipts(hash common.Hash) (types.Receipts, error) {
-	panic("implement")
+func (s DiskStore) ReadReceipts(receiptRoot common.Hash) (types.Receipts, error) {
+	return readReceipts(s, receiptRoot)
 }
 
 func (s DiskStore) ReadNode(nodeHash common.Hash) (node []byte, err error) {
@@ -126,33 +191,77 @@ func (s DiskStore) ReadNode(nodeHash common.Hash) (node []byte, err error) {
 }
 
 func (s DiskStore) read(hash common.Hash, restore func(io.Reader) error) error {
-	f, err := os.Open(s.fileName(hash))
+	record, err := os.ReadFile(s.fileName(hash))
 	if errors.Is(err, fs.ErrNotExist) {
 		return NoDataError{hash}
 	} else if err != nil {
-		return fmt.Errorf("opening file: %w", err)
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if s.layout < 3 {
+		// older layouts store the raw pre-images
+		return restore(bytes.NewReader(record))
 	}
-	return restore(f)
+	value, err := decodeRecord(record)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", hash, err)
+	}
+	return restore(bytes.NewReader(value))
 }
 
-type keyValueWriter struct {
-	s DiskStore
+// Keys returns the keys of all pre-images in the store.
+func (s DiskStore) Keys() ([]common.Hash, error) {
+	shards, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing storage dir: %w", err)
+	}
+	if s.layout == 1 {
+		return fileKeys(shards), nil
+	}
+	var keys []common.Hash
+	for _, shard := range shards {
+		if !shard.IsDir() || !isShardName(shard.Name()) {
+			// not a shard, like the checkpoints dir
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, shard.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("listing shard %s: %w", shard.Name(), err)
+		}
+		keys = append(keys, fileKeys(entries)...)
+	}
+	return keys, nil
 }
 
-func (k keyValueWriter) Put(key []byte, value []byte) error {
-	return k.s.store(common.BytesToHash(key), func(w io.Writer) error {
-		_, err := w.Write(value)
-		return err
-	})
+// fileKeys returns the keys of the pre-image files among the dir entries.
+func fileKeys(entries []fs.DirEntry) []common.Hash {
+	var keys []common.Hash
+	for _, e := range entries {
+		var key common.Hash
+		if e.IsDir() || key.UnmarshalText([]byte(e.Name())) != nil {
+			// not a pre-image, like a temporary file of an interrupted write
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
 }
 
-func (k keyValueWriter) Delete(key []byte) error {
-	return errors.New("delete not supported")
+func isShardName(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == 1 && name == hex.EncodeToString(b)
 }
 
-type noResetTrie struct {
-	trie.StackTrie
+func (s DiskStore) Delete(key common.Hash) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := os.Remove(s.fileName(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", key, err)
+	}
+	return nil
 }
 
-func (t *noResetTrie) Reset() {
+// Close is a no-op, files are closed after every read and write.
+func (s DiskStore) Close() error {
+	return nil
 }
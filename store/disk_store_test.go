@@ -1,11 +1,18 @@
 package store_test
 
 import (
+	"encoding/hex"
 	"math/rand"
 	"op-mordor/store"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,15 +47,119 @@ func TestDiskStore(t *testing.T) {
 	})
 
 	t.Run("Store+ReadNode", func(t *testing.T) {
+		// pre-images are never rewritten, so use a key that is not taken by the header
+		nodeHash := testutils.RandomHash(rng)
 		rndNode := testutils.RandomData(rng, 420)
-		require.NoError(t, s.StoreNode(rndHash, rndNode))
+		require.NoError(t, s.StoreNode(nodeHash, rndNode))
 
-		node, err := s.ReadNode(rndHash)
+		node, err := s.ReadNode(nodeHash)
+		require.NoError(t, err)
+		require.Equal(t, node, rndNode)
+	})
+
+	t.Run("StoreNode/skip-existing", func(t *testing.T) {
+		nodeHash := testutils.RandomHash(rng)
+		rndNode := testutils.RandomData(rng, 42)
+		require.NoError(t, s.StoreNode(nodeHash, rndNode))
+		require.NoError(t, s.StoreNode(nodeHash, testutils.RandomData(rng, 42)))
+
+		node, err := s.ReadNode(nodeHash)
 		require.NoError(t, err)
 		require.Equal(t, node, rndNode)
 	})
 }
 
+func TestDiskStoreCompression(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	storePath := t.TempDir()
+
+	plain, err := store.NewDiskStore(storePath)
+	require.NoError(t, err)
+	plainNode := testutils.RandomData(rng, 420)
+	require.NoError(t, plain.StoreNode(crypto.Keccak256Hash(plainNode), plainNode))
+
+	compressed, err := store.NewDiskStoreWithCompression(storePath, store.SnappyCompression)
+	require.NoError(t, err)
+	compressedNode := make([]byte, 420)
+	require.NoError(t, compressed.StoreNode(crypto.Keccak256Hash(compressedNode), compressedNode))
+
+	for _, node := range [][]byte{plainNode, compressedNode} {
+		for _, s := range []*store.DiskStore{plain, compressed} {
+			value, err := s.ReadNode(crypto.Keccak256Hash(node))
+			require.NoError(t, err)
+			require.Equal(t, node, value)
+		}
+	}
+}
+
+func TestDiskStoreMigration(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	storePath := t.TempDir()
+
+	// a version 1 store keeps all raw pre-images in the store dir
+	rndNode := testutils.RandomData(rng, 420)
+	rndHash := crypto.Keccak256Hash(rndNode)
+	require.NoError(t, os.WriteFile(filepath.Join(storePath, rndHash.Hex()), rndNode, 0666))
+
+	s, err := store.NewDiskStore(storePath)
+	require.NoError(t, err)
+	node, err := s.ReadNode(rndHash)
+	require.NoError(t, err)
+	require.Equal(t, rndNode, node)
+	keys, err := s.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []common.Hash{rndHash}, keys)
+	require.NoFileExists(t, filepath.Join(storePath, rndHash.Hex()))
+
+	require.NoError(t, os.WriteFile(filepath.Join(storePath, "LAYOUT"), []byte("4\n"), 0666))
+	_, err = store.NewDiskStore(storePath)
+	require.ErrorContains(t, err, "unsupported store layout version 4")
+}
+
+func TestDiskStoreReadOnly(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	storePath := t.TempDir()
+
+	// a version 2 store keeps the raw pre-images in shard dirs, a corrupt one included
+	node := append([]byte{0x60}, testutils.RandomData(rng, 420)...)
+	key := crypto.Keccak256Hash(node)
+	corruptKey := testutils.RandomHash(rng)
+	for k, value := range map[common.Hash][]byte{key: node, corruptKey: {0x07, 0x01}} {
+		require.NoError(t, os.MkdirAll(filepath.Join(storePath, hex.EncodeToString(k[:1])), 0777))
+		require.NoError(t, os.WriteFile(filepath.Join(storePath, hex.EncodeToString(k[:1]), k.Hex()), value, 0666))
+	}
+	layoutPath := filepath.Join(storePath, "LAYOUT")
+	require.NoError(t, os.WriteFile(layoutPath, []byte("2\n"), 0666))
+
+	s, err := store.OpenDiskStoreReadOnly(storePath)
+	require.NoError(t, err)
+	value, err := s.ReadNode(key)
+	require.NoError(t, err)
+	require.Equal(t, node, value)
+	require.ErrorIs(t, s.StoreNode(testutils.RandomHash(rng), node), store.ErrReadOnly)
+	require.ErrorIs(t, s.Delete(key), store.ErrReadOnly)
+	report, err := store.Verify(s, store.VerifyOptions{})
+	require.NoError(t, err)
+	require.Empty(t, report.Undecodable)
+	require.Len(t, report.Mismatches, 1)
+	require.Equal(t, corruptKey, report.Mismatches[0].Key)
+	layout, err := os.ReadFile(layoutPath)
+	require.NoError(t, err)
+	require.Equal(t, "2\n", string(layout), "read-only stores are not migrated")
+
+	// the corrupt pre-image is still a mismatch after the migration
+	migrated, err := store.NewDiskStore(storePath)
+	require.NoError(t, err)
+	report, err = store.Verify(migrated, store.VerifyOptions{})
+	require.NoError(t, err)
+	require.Empty(t, report.Undecodable)
+	require.Len(t, report.Mismatches, 1)
+	require.Equal(t, corruptKey, report.Mismatches[0].Key)
+
+	_, err = store.OpenDiskStoreReadOnly(filepath.Join(storePath, "missing"))
+	require.Error(t, err)
+}
+
 func TestBlockStoreSource(t *testing.T) {
 	rng := rand.New(rand.NewSource(420))
 
@@ -71,10 +182,20 @@ func TestBlockStoreSource(t *testing.T) {
 		rndBlock, _ := testutils.RandomBlock(rng, 16)
 		require.NoError(t, bstore.StoreBlock(rndBlock))
 
-		// TODO: enable after ReadTransactions is implemented
-		//block, err := bsource.ReadBlock(rndBlock.Hash())
-		//require.NoError(t, err)
-		//require.Equal(t, block, rndBlock)
+		block, err := bsource.ReadBlock(rndBlock.Hash())
+		require.NoError(t, err)
+		require.Equal(t, rndBlock.Hash(), block.Hash())
+		require.Equal(t, rndBlock.TxHash(), types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)))
+	})
+
+	t.Run("Store+ReadReceipts", func(t *testing.T) {
+		rndBlock, rndReceipts := testutils.RandomBlock(rng, 8)
+		require.NoError(t, s.StoreReceipts(rndReceipts))
+
+		receipts, err := s.ReadReceipts(rndBlock.ReceiptHash())
+		require.NoError(t, err)
+		require.Len(t, receipts, len(rndReceipts))
+		require.Equal(t, rndBlock.ReceiptHash(), types.DeriveSha(types.Receipts(receipts), trie.NewStackTrie(nil)))
 	})
 }
 
@@ -82,3 +203,52 @@ func requireNoDataError(t *testing.T, err error) {
 	var noDataErr store.NoDataError
 	require.ErrorAs(t, err, &noDataErr)
 }
+
+// BenchmarkDiskStoreCompression copies a recorded witness, like one exported with run --witness, into a disk store
+// per compression, and reports the size on disk. Set MORDOR_BENCH_WITNESS to the witness store path to run it.
+func BenchmarkDiskStoreCompression(b *testing.B) {
+	witnessPath := os.Getenv("MORDOR_BENCH_WITNESS")
+	if witnessPath == "" {
+		b.Skip("MORDOR_BENCH_WITNESS is not set")
+	}
+	witness, err := store.Open(witnessPath, store.NoCompression)
+	require.NoError(b, err)
+	defer witness.Close()
+	keys, err := witness.Keys()
+	require.NoError(b, err)
+	values := make([][]byte, len(keys))
+	var size int64
+	for i, key := range keys {
+		values[i], err = witness.ReadNode(key)
+		require.NoError(b, err)
+		size += int64(len(values[i]))
+	}
+
+	for _, compression := range []string{"none", "snappy"} {
+		b.Run(compression, func(b *testing.B) {
+			c, err := store.ParseCompression(compression)
+			require.NoError(b, err)
+			b.SetBytes(size)
+			var onDisk int64
+			for n := 0; n < b.N; n++ {
+				dir := b.TempDir()
+				s, err := store.NewDiskStoreWithCompression(dir, c)
+				require.NoError(b, err)
+				for i, key := range keys {
+					require.NoError(b, s.StoreNode(key, values[i]))
+				}
+				b.StopTimer()
+				onDisk = 0
+				require.NoError(b, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+					if err == nil && !info.IsDir() {
+						onDisk += info.Size()
+					}
+					return err
+				}))
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(onDisk), "disk-bytes")
+			b.ReportMetric(float64(onDisk)/float64(size), "disk-ratio")
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Chain is the chain a header belongs to, as the L1 and L2 block numbers are unrelated.
+type Chain string
+
+const (
+	L1Chain Chain = "l1"
+	L2Chain Chain = "l2"
+)
+
+// Collector marks the pre-images to keep, to collect all other pre-images of a store as garbage.
+// Headers below the eviction block number of their chain are never kept, and end the chains kept by KeepChain.
+type Collector struct {
+	src    KeySource
+	before map[Chain]uint64
+	// w marks the nodes of the walked tries
+	w *trieWalker
+	// kept are the kept headers and single pre-images
+	kept map[common.Hash]struct{}
+	// chains are the headers kept with their ancestors
+	chains map[common.Hash]struct{}
+}
+
+// NewCollector creates a collector for the source that evicts all L1 headers with a number below beforeL1,
+// and all L2 headers with a number below beforeL2.
+func NewCollector(src KeySource, beforeL1 uint64, beforeL2 uint64) *Collector {
+	return &Collector{
+		src:    src,
+		before: map[Chain]uint64{L1Chain: beforeL1, L2Chain: beforeL2},
+		w:      newTrieWalker(src),
+		kept:   make(map[common.Hash]struct{}),
+		chains: make(map[common.Hash]struct{}),
+	}
+}
+
+// KeepChain keeps the header with the given hash, its tries and state, and the same of all its stored ancestors.
+func (c *Collector) KeepChain(chain Chain, head common.Hash) error {
+	for hash := head; ; {
+		if _, ok := c.chains[hash]; ok {
+			return nil
+		}
+		h, err := c.keepHeader(chain, hash)
+		if err != nil || h == nil {
+			return err
+		}
+		c.chains[hash] = struct{}{}
+		c.w.walk(h.Root, StatePreimage)
+		c.w.walk(h.TxHash, TransactionsPreimage)
+		c.w.walk(h.ReceiptHash, ReceiptsPreimage)
+		hash = h.ParentHash
+	}
+}
+
+// KeepHeader keeps the header with the given hash, and optionally its transactions and receipts tries.
+func (c *Collector) KeepHeader(chain Chain, hash common.Hash, txs bool, receipts bool) error {
+	h, err := c.keepHeader(chain, hash)
+	if err != nil || h == nil {
+		return err
+	}
+	if txs {
+		c.w.walk(h.TxHash, TransactionsPreimage)
+	}
+	if receipts {
+		c.w.walk(h.ReceiptHash, ReceiptsPreimage)
+	}
+	return nil
+}
+
+// keepHeader keeps the header, and returns it. It returns nil if the header is not stored or evicted.
+func (c *Collector) keepHeader(chain Chain, hash common.Hash) (*types.Header, error) {
+	h, err := c.src.ReadHeader(hash)
+	if IsNoDataError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading header %s: %w", hash, err)
+	}
+	if h.Number.Uint64() < c.before[chain] {
+		return nil, nil
+	}
+	c.kept[hash] = struct{}{}
+	return h, nil
+}
+
+// KeepNode keeps a single pre-image.
+func (c *Collector) KeepNode(hash common.Hash) {
+	c.kept[hash] = struct{}{}
+}
+
+// KeepAll keeps the chains of all stored headers, so only orphaned pre-images are garbage.
+// The chain of a stored header is unknown, L2 headers are often stored without their state, so KeepAll
+// cannot be combined with evicting headers. The roots passed to KeepChain and KeepHeader tell the chain instead.
+func (c *Collector) KeepAll() error {
+	if c.before[L1Chain] > 0 || c.before[L2Chain] > 0 {
+		return errors.New("cannot keep all headers while evicting headers, the chain of a stored header is unknown")
+	}
+	keys, err := c.src.Keys()
+	if err != nil {
+		return fmt.Errorf("listing pre-images: %w", err)
+	}
+	for _, key := range keys {
+		value, err := c.src.ReadNode(key)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		if kind, _, err := classifyEntry(value); err != nil || kind != HeaderEntry {
+			continue
+		}
+		// nothing is evicted, so the chain does not matter
+		if err := c.KeepChain(L1Chain, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Garbage returns the keys of all pre-images that are not kept.
+func (c *Collector) Garbage() ([]common.Hash, error) {
+	keys, err := c.src.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("listing pre-images: %w", err)
+	}
+	var garbage []common.Hash
+	for _, key := range keys {
+		_, kept := c.kept[key]
+		_, reached := c.w.reached[key]
+		if !kept && !reached {
+			garbage = append(garbage, key)
+		}
+	}
+	return garbage, nil
+}
@@ -0,0 +1,105 @@
+package store_test
+
+import (
+	"math/big"
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	s := store.NewMemoryStore()
+
+	parent, _ := testutils.RandomBlock(rng, 4)
+	head := testutils.RandomHeader(rng)
+	head.Number = new(big.Int).Add(parent.Number(), common.Big1)
+	head.ParentHash = parent.Hash()
+	head.TxHash = types.EmptyRootHash
+	other, _ := testutils.RandomBlock(rng, 4)
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(parent))
+	require.NoError(t, s.StoreHeader(head.Hash(), head))
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(other))
+	orphan := testutils.RandomHash(rng)
+	require.NoError(t, s.StoreNode(orphan, testutils.RandomData(rng, 10)))
+
+	keys := func(block *types.Block) []common.Hash {
+		src := store.NewMemoryStore()
+		require.NoError(t, store.BlockStore{Store: src}.StoreBlock(block))
+		return mustKeys(t, src)
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		c := store.NewCollector(s, 0, 0)
+		require.NoError(t, c.KeepChain(store.L1Chain, head.Hash()))
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.ElementsMatch(t, append(keys(other), orphan), garbage)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		c := store.NewCollector(s, 0, 0)
+		require.NoError(t, c.KeepAll())
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.Equal(t, []common.Hash{orphan}, garbage)
+	})
+
+	t.Run("before", func(t *testing.T) {
+		c := store.NewCollector(s, head.Number.Uint64(), 0)
+		require.NoError(t, c.KeepChain(store.L1Chain, head.Hash()))
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.ElementsMatch(t, append(append(keys(other), keys(parent)...), orphan), garbage)
+	})
+
+	t.Run("before other chain", func(t *testing.T) {
+		c := store.NewCollector(s, 0, head.Number.Uint64())
+		require.NoError(t, c.KeepChain(store.L1Chain, head.Hash()))
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.ElementsMatch(t, append(keys(other), orphan), garbage)
+	})
+
+	t.Run("all with eviction", func(t *testing.T) {
+		c := store.NewCollector(s, 0, head.Number.Uint64())
+		require.Error(t, c.KeepAll())
+	})
+}
+
+func TestCollectorL2WithoutState(t *testing.T) {
+	rng := rand.New(rand.NewSource(421))
+	s := store.NewMemoryStore()
+
+	// L2 blocks loaded as ancestors are stored without their state
+	parent, _ := testutils.RandomBlock(rng, 2)
+	head := testutils.RandomHeader(rng)
+	head.Number = new(big.Int).Add(parent.Number(), common.Big1)
+	head.ParentHash = parent.Hash()
+	head.TxHash = types.EmptyRootHash
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(parent))
+	require.NoError(t, s.StoreHeader(head.Hash(), head))
+	parentKeys := store.NewMemoryStore()
+	require.NoError(t, store.BlockStore{Store: parentKeys}.StoreBlock(parent))
+
+	t.Run("l1 eviction", func(t *testing.T) {
+		c := store.NewCollector(s, head.Number.Uint64(), 0)
+		require.NoError(t, c.KeepChain(store.L2Chain, head.Hash()))
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.Empty(t, garbage)
+	})
+
+	t.Run("l2 eviction", func(t *testing.T) {
+		c := store.NewCollector(s, 0, head.Number.Uint64())
+		require.NoError(t, c.KeepChain(store.L2Chain, head.Hash()))
+		garbage, err := c.Garbage()
+		require.NoError(t, err)
+		require.ElementsMatch(t, mustKeys(t, parentKeys), garbage)
+	})
+}
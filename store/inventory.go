@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// PreimageKind is the kind of a stored pre-image, as determined by the tries that reach it.
+type PreimageKind string
+
+const (
+	HeaderPreimage       PreimageKind = "headers"
+	TransactionsPreimage PreimageKind = "transactions"
+	ReceiptsPreimage     PreimageKind = "receipts"
+	// StatePreimage is a node of the state trie, or of a storage trie.
+	StatePreimage PreimageKind = "state"
+	CodePreimage  PreimageKind = "code"
+	// UnknownPreimage is a trie node that is not reachable from any stored header, or an undecodable entry.
+	UnknownPreimage PreimageKind = "unknown"
+)
+
+// PreimageKinds lists the pre-image kinds in reporting order.
+var PreimageKinds = []PreimageKind{HeaderPreimage, TransactionsPreimage, ReceiptsPreimage, StatePreimage, CodePreimage, UnknownPreimage}
+
+// InventoryEntry is a stored pre-image.
+type InventoryEntry struct {
+	Key  common.Hash
+	Kind PreimageKind
+	Size uint64
+	// Header is the decoded header, for header entries.
+	Header *types.Header
+}
+
+// BlockRange is a run of stored headers with consecutive numbers, each the parent of the next.
+type BlockRange struct {
+	// Chain is "l2" if the state of any header of the range is stored, "l1" otherwise.
+	Chain string
+	First uint64
+	Last  uint64
+}
+
+// Inventory describes the pre-images of a store.
+type Inventory struct {
+	// Entries are all pre-images, sorted by key.
+	Entries []InventoryEntry
+	Stats   map[PreimageKind]PreimageStats
+	// Ranges are the block ranges of the stored headers, sorted by chain and first block number.
+	Ranges []BlockRange
+}
+
+// NewInventory reads all pre-images of the source, and classifies them by walking the tries of the stored headers.
+func NewInventory(src KeySource) (*Inventory, error) {
+	keys, err := src.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("listing pre-images: %w", err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	inv := &Inventory{Entries: make([]InventoryEntry, 0, len(keys)), Stats: make(map[PreimageKind]PreimageStats)}
+	var headers []*types.Header
+	for _, key := range keys {
+		value, err := src.ReadNode(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", key, err)
+		}
+		e := InventoryEntry{Key: key, Kind: UnknownPreimage, Size: uint64(len(value))}
+		switch kind, header, err := classifyEntry(value); {
+		case err != nil:
+		case kind == HeaderEntry:
+			e.Kind, e.Header = HeaderPreimage, header
+			headers = append(headers, header)
+		case kind == CodeEntry:
+			e.Kind = CodePreimage
+		}
+		inv.Entries = append(inv.Entries, e)
+	}
+
+	w := newTrieWalker(src)
+	for _, h := range headers {
+		w.walk(h.Root, StatePreimage)
+		w.walk(h.TxHash, TransactionsPreimage)
+		w.walk(h.ReceiptHash, ReceiptsPreimage)
+	}
+	for i := range inv.Entries {
+		e := &inv.Entries[i]
+		if kind, ok := w.reached[e.Key]; ok && e.Kind == UnknownPreimage {
+			e.Kind = kind
+		}
+		s := inv.Stats[e.Kind]
+		s.Count++
+		s.Bytes += e.Size
+		inv.Stats[e.Kind] = s
+	}
+	inv.Ranges = blockRanges(headers, func(h *types.Header) bool {
+		_, ok := w.reached[h.Root]
+		return ok
+	})
+	return inv, nil
+}
+
+// blockRanges groups the headers into runs of consecutive blocks.
+func blockRanges(headers []*types.Header, hasState func(h *types.Header) bool) []BlockRange {
+	byHash := make(map[common.Hash]*types.Header, len(headers))
+	for _, h := range headers {
+		byHash[h.Hash()] = h
+	}
+	// start a range at every header whose parent is not stored
+	var ranges []BlockRange
+	children := make(map[common.Hash]*types.Header, len(headers))
+	for _, h := range headers {
+		children[h.ParentHash] = h
+	}
+	for _, h := range headers {
+		if _, ok := byHash[h.ParentHash]; ok {
+			continue
+		}
+		r := BlockRange{Chain: "l1", First: h.Number.Uint64(), Last: h.Number.Uint64()}
+		for cur := h; cur != nil; cur = children[cur.Hash()] {
+			r.Last = cur.Number.Uint64()
+			if hasState(cur) {
+				r.Chain = "l2"
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Chain != ranges[j].Chain {
+			return ranges[i].Chain < ranges[j].Chain
+		}
+		return ranges[i].First < ranges[j].First
+	})
+	return ranges
+}
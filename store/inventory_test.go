@@ -0,0 +1,57 @@
+package store_test
+
+import (
+	"math/big"
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	s := store.NewMemoryStore()
+
+	code := []byte{0x60, 0x00, 0x60, 0x00}
+	account, err := rlp.EncodeToBytes(&types.StateAccount{Nonce: 1, Balance: big.NewInt(1), Root: types.EmptyRootHash, CodeHash: crypto.Keccak256(code)})
+	require.NoError(t, err)
+	leaf, err := rlp.EncodeToBytes([][]byte{append([]byte{0x20}, testutils.RandomData(rng, 32)...), account})
+	require.NoError(t, err)
+	require.NoError(t, s.StoreNode(crypto.Keccak256Hash(leaf), leaf))
+	require.NoError(t, s.StoreNode(crypto.Keccak256Hash(code), code))
+
+	// l1 blocks 10-11, l2 blocks 100-101 with the state of block 101
+	l1a := testutils.RandomHeader(rng)
+	l1a.Number = big.NewInt(10)
+	l1b := testutils.RandomHeader(rng)
+	l1b.Number, l1b.ParentHash = big.NewInt(11), l1a.Hash()
+	l2a := testutils.RandomHeader(rng)
+	l2a.Number = big.NewInt(100)
+	l2b := testutils.RandomHeader(rng)
+	l2b.Number, l2b.ParentHash, l2b.Root = big.NewInt(101), l2a.Hash(), crypto.Keccak256Hash(leaf)
+	for _, h := range []*types.Header{l1a, l1b, l2a, l2b} {
+		require.NoError(t, s.StoreHeader(h.Hash(), h))
+	}
+	block, _ := testutils.RandomBlock(rng, 4)
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(block))
+
+	inv, err := store.NewInventory(s)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), inv.Stats[store.HeaderPreimage].Count)
+	require.NotZero(t, inv.Stats[store.TransactionsPreimage].Count)
+	require.Equal(t, store.PreimageStats{Count: 1, Bytes: uint64(len(leaf))}, inv.Stats[store.StatePreimage])
+	require.Equal(t, store.PreimageStats{Count: 1, Bytes: uint64(len(code))}, inv.Stats[store.CodePreimage])
+	require.Zero(t, inv.Stats[store.UnknownPreimage].Count)
+
+	n := block.NumberU64()
+	require.Equal(t, []store.BlockRange{
+		{Chain: "l1", First: 10, Last: 11},
+		{Chain: "l1", First: n, Last: n},
+		{Chain: "l2", First: 100, Last: 101},
+	}, inv.Ranges)
+}
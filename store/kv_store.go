@@ -0,0 +1,171 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// checkpointPrefix prefixes the keys of checkpoints in a KVStore. These never collide with the 32-byte pre-image keys.
+var checkpointPrefix = []byte("checkpoint-")
+
+// KVStore stores pre-images in a key-value database, keyed by their hash.
+// The nodes of a transactions or receipts trie are written in a single batch.
+type KVStore struct {
+	db ethdb.KeyValueStore
+}
+
+func NewKVStore(db ethdb.KeyValueStore) *KVStore {
+	return &KVStore{db: db}
+}
+
+// OpenLevelDB opens the LevelDB database in the directory as store, creating it if needed.
+func OpenLevelDB(dir string) (*KVStore, error) {
+	db, err := leveldb.New(dir, 128, 256, "", false)
+	if err != nil {
+		return nil, fmt.Errorf("opening leveldb: %w", err)
+	}
+	return NewKVStore(db), nil
+}
+
+// OpenLevelDBReadOnly opens the existing LevelDB database in the directory as store, without writing to it.
+func OpenLevelDBReadOnly(dir string) (*KVStore, error) {
+	db, err := leveldb.New(dir, 128, 256, "", true)
+	if err != nil {
+		return nil, fmt.Errorf("opening leveldb: %w", err)
+	}
+	return NewKVStore(db), nil
+}
+
+func (s *KVStore) StoreHeader(hash common.Hash, header *types.Header) error {
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return fmt.Errorf("encoding header: %w", err)
+	}
+	return s.StoreNode(hash, data)
+}
+
+func (s *KVStore) StoreTransactions(txRoot common.Hash, txs types.Transactions) error {
+	batch := s.db.NewBatch()
+	if err := storeTransactions(batch, txRoot, txs); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("writing tx batch: %w", err)
+	}
+	return nil
+}
+
+func (s *KVStore) StoreReceipts(receipts types.Receipts) error {
+	batch := s.db.NewBatch()
+	if err := storeReceipts(batch, receipts); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("writing receipts batch: %w", err)
+	}
+	return nil
+}
+
+func (s *KVStore) StoreNode(nodeHash common.Hash, node []byte) error {
+	if err := s.db.Put(nodeHash[:], node); err != nil {
+		return fmt.Errorf("writing %s: %w", nodeHash, err)
+	}
+	return nil
+}
+
+func (s *KVStore) ReadHeader(hash common.Hash) (*types.Header, error) {
+	data, err := s.ReadNode(hash)
+	if err != nil {
+		return nil, err
+	}
+	var header types.Header
+	if err := rlp.DecodeBytes(data, &header); err != nil {
+		return nil, fmt.Errorf("decoding header %s: %w", hash, err)
+	}
+	return &header, nil
+}
+
+func (s *KVStore) ReadTransactions(txRoot common.Hash) (types.Transactions, error) {
+	return readTransactions(s, txRoot)
+}
+
+func (s *KVStore) ReadReceipts(receiptRoot common.Hash) (types.Receipts, error) {
+	return readReceipts(s, receiptRoot)
+}
+
+func (s *KVStore) ReadNode(nodeHash common.Hash) ([]byte, error) {
+	return s.get(nodeHash[:], nodeHash)
+}
+
+// get reads the key, and returns a NoDataError for the id if the key is absent.
+// Databases differ in their not-found errors, so absence is only checked when reading fails.
+func (s *KVStore) get(key []byte, id common.Hash) ([]byte, error) {
+	value, err := s.db.Get(key)
+	if err == nil {
+		return value, nil
+	}
+	if ok, hasErr := s.db.Has(key); hasErr == nil && !ok {
+		return nil, NoDataError{id}
+	}
+	return nil, fmt.Errorf("reading %s: %w", id, err)
+}
+
+func (s *KVStore) StoreCheckpoint(id common.Hash, checkpoint []byte) error {
+	if err := s.db.Put(append(checkpointPrefix, id[:]...), checkpoint); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *KVStore) ReadCheckpoint(id common.Hash) ([]byte, error) {
+	return s.get(append(checkpointPrefix, id[:]...), id)
+}
+
+// Checkpoints returns the ids of all stored checkpoints.
+func (s *KVStore) Checkpoints() ([]common.Hash, error) {
+	it := s.db.NewIterator(checkpointPrefix, nil)
+	defer it.Release()
+	var ids []common.Hash
+	for it.Next() {
+		if len(it.Key()) == len(checkpointPrefix)+common.HashLength {
+			ids = append(ids, common.BytesToHash(it.Key()[len(checkpointPrefix):]))
+		}
+	}
+	return ids, it.Error()
+}
+
+func (s *KVStore) DeleteCheckpoint(id common.Hash) error {
+	if err := s.db.Delete(append(checkpointPrefix, id[:]...)); err != nil {
+		return fmt.Errorf("removing checkpoint %s: %w", id, err)
+	}
+	return nil
+}
+
+// Keys returns the keys of all pre-images in the store.
+func (s *KVStore) Keys() ([]common.Hash, error) {
+	it := s.db.NewIterator(nil, nil)
+	defer it.Release()
+	var keys []common.Hash
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			keys = append(keys, common.BytesToHash(it.Key()))
+		}
+	}
+	return keys, it.Error()
+}
+
+func (s *KVStore) Delete(key common.Hash) error {
+	if err := s.db.Delete(key[:]); err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *KVStore) Close() error {
+	return s.db.Close()
+}
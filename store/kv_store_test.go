@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"math/rand"
+	"op-mordor/store"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVStore(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+
+	dir := filepath.Join(t.TempDir(), "db")
+	backend, err := store.Open(store.LevelDBScheme+dir, store.NoCompression)
+	require.NoError(t, err)
+	require.IsType(t, (*store.KVStore)(nil), backend)
+
+	rndHash := testutils.RandomHash(rng)
+	_, err = backend.ReadNode(rndHash)
+	requireNoDataError(t, err)
+	_, err = backend.ReadCheckpoint(rndHash)
+	requireNoDataError(t, err)
+
+	block, _ := testutils.RandomBlock(rng, 16)
+	require.NoError(t, store.BlockStore{Store: backend}.StoreBlock(block))
+	rndNode := testutils.RandomData(rng, 420)
+	require.NoError(t, backend.StoreNode(rndHash, rndNode))
+	require.NoError(t, backend.StoreCheckpoint(rndHash, []byte("checkpoint")))
+	require.NoError(t, backend.Close())
+
+	backend, err = store.Open(store.LevelDBScheme+dir, store.NoCompression)
+	require.NoError(t, err)
+	defer backend.Close()
+
+	read, err := store.BlockSource{Source: backend}.ReadBlock(block.Hash())
+	require.NoError(t, err)
+	require.Equal(t, block.Hash(), read.Hash())
+	require.Len(t, read.Transactions(), len(block.Transactions()))
+	node, err := backend.ReadNode(rndHash)
+	require.NoError(t, err)
+	require.Equal(t, rndNode, node)
+	checkpoint, err := backend.ReadCheckpoint(rndHash)
+	require.NoError(t, err)
+	require.Equal(t, []byte("checkpoint"), checkpoint)
+
+	keys, err := backend.Keys()
+	require.NoError(t, err)
+	require.Contains(t, keys, rndHash)
+	require.Contains(t, keys, block.Hash())
+
+	ids, err := backend.Checkpoints()
+	require.NoError(t, err)
+	require.Equal(t, []common.Hash{rndHash}, ids)
+	require.NoError(t, backend.DeleteCheckpoint(rndHash))
+	_, err = backend.ReadCheckpoint(rndHash)
+	requireNoDataError(t, err)
+}
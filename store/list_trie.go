@@ -0,0 +1,191 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// nodeReader exposes the nodes of a Source as a key-value store, to open tries on top of a Source.
+// Only the read methods used by the trie database are implemented.
+type nodeReader struct {
+	ethdb.KeyValueStore
+	source Source
+}
+
+func (r nodeReader) Has(key []byte) (bool, error) {
+	_, err := r.Get(key)
+	if IsNoDataError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r nodeReader) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return nil, fmt.Errorf("can only read 32-byte keys, got %d bytes", len(key))
+	}
+	return r.source.ReadNode(common.BytesToHash(key))
+}
+
+// readList reads the values of a trie keyed by RLP-encoded index, like a transactions or receipts trie,
+// from the nodes of the source, in index order.
+func readList(source Source, root common.Hash) ([][]byte, error) {
+	if root == types.EmptyRootHash {
+		return nil, nil
+	}
+	t, err := trie.New(trie.TrieID(root), trie.NewDatabase(nodeReader{source: source}))
+	if err != nil {
+		return nil, missingAsNoData(fmt.Errorf("opening trie %s: %w", root, err))
+	}
+	values := make(map[uint64][]byte)
+	it := trie.NewIterator(t.NodeIterator(nil))
+	for it.Next() {
+		var index uint64
+		if err := rlp.DecodeBytes(it.Key, &index); err != nil {
+			return nil, fmt.Errorf("invalid list trie key %x: %w", it.Key, err)
+		}
+		values[index] = it.Value
+	}
+	if it.Err != nil {
+		return nil, missingAsNoData(fmt.Errorf("reading trie %s: %w", root, it.Err))
+	}
+	out := make([][]byte, len(values))
+	for i := range out {
+		v, ok := values[uint64(i)]
+		if !ok {
+			return nil, fmt.Errorf("trie %s has no value at index %d", root, i)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// missingAsNoData turns a missing trie node error into a NoDataError, since an incompletely stored trie
+// is as good as an absent one.
+func missingAsNoData(err error) error {
+	var missing *trie.MissingNodeError
+	if errors.As(err, &missing) {
+		return NoDataError{missing.NodeHash}
+	}
+	return err
+}
+
+// readTransactions reads the transactions of the transactions trie with the given root from the source.
+func readTransactions(source Source, txRoot common.Hash) (types.Transactions, error) {
+	values, err := readList(source, txRoot)
+	if err != nil {
+		return nil, err
+	}
+	txs := make(types.Transactions, len(values))
+	for i, v := range values {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(v); err != nil {
+			return nil, fmt.Errorf("decoding tx %d: %w", i, err)
+		}
+		txs[i] = &tx
+	}
+	return txs, nil
+}
+
+// readReceipts reads the receipts of the receipts trie with the given root from the source.
+// Only the consensus fields of the receipts are stored, the other fields are not set.
+func readReceipts(source Source, receiptRoot common.Hash) (types.Receipts, error) {
+	values, err := readList(source, receiptRoot)
+	if err != nil {
+		return nil, err
+	}
+	receipts := make(types.Receipts, len(values))
+	for i, v := range values {
+		var receipt types.Receipt
+		if err := receipt.UnmarshalBinary(v); err != nil {
+			return nil, fmt.Errorf("decoding receipt %d: %w", i, err)
+		}
+		receipts[i] = &receipt
+	}
+	return receipts, nil
+}
+
+// DeriveReceiptFields sets the fields of receipts read from a store that are not part of the consensus encoding,
+// like the block hash and index of the logs, from the header and transactions of their block.
+// Unlike types.Receipts.DeriveFields it needs no chain config, so the contract address and
+// effective gas price are left unset.
+func DeriveReceiptFields(receipts types.Receipts, header *types.Header, txs types.Transactions) error {
+	if len(receipts) != len(txs) {
+		return fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(txs))
+	}
+	blockHash := header.Hash()
+	var logIndex uint
+	for i, r := range receipts {
+		r.Type = txs[i].Type()
+		r.TxHash = txs[i].Hash()
+		r.BlockHash = blockHash
+		r.BlockNumber = header.Number
+		r.TransactionIndex = uint(i)
+		r.GasUsed = r.CumulativeGasUsed
+		if i > 0 {
+			r.GasUsed -= receipts[i-1].CumulativeGasUsed
+		}
+		for _, lg := range r.Logs {
+			lg.BlockNumber = header.Number.Uint64()
+			lg.BlockHash = blockHash
+			lg.TxHash = r.TxHash
+			lg.TxIndex = uint(i)
+			lg.Index = logIndex
+			logIndex++
+		}
+	}
+	return nil
+}
+
+// storeTransactions writes the nodes of the transactions trie, after checking it matches the expected root.
+func storeTransactions(pkw ethdb.KeyValueWriter, txRoot common.Hash, txs types.Transactions) error {
+	hasher := &noResetTrie{*trie.NewStackTrie(pkw)}
+
+	testTxHash := types.DeriveSha(txs, hasher)
+	if testTxHash != txRoot {
+		return fmt.Errorf("expected txRoot %s does not match actual root %s", txRoot, testTxHash)
+	}
+	_, err := hasher.Commit()
+	if err != nil {
+		return fmt.Errorf("store tx: %w", err)
+	}
+	return nil
+}
+
+// storeReceipts writes the nodes of the receipts trie.
+func storeReceipts(pkw ethdb.KeyValueWriter, receipts types.Receipts) error {
+	hasher := &noResetTrie{*trie.NewStackTrie(pkw)}
+
+	types.DeriveSha(receipts, hasher)
+	_, err := hasher.Commit()
+	if err != nil {
+		return fmt.Errorf("store receipts: %w", err)
+	}
+	return nil
+}
+
+// keyValueWriter stores the nodes written by a stack trie as nodes of the store.
+type keyValueWriter struct {
+	s Store
+}
+
+func (k keyValueWriter) Put(key []byte, value []byte) error {
+	return k.s.StoreNode(common.BytesToHash(key), value)
+}
+
+func (k keyValueWriter) Delete(key []byte) error {
+	return errors.New("delete not supported")
+}
+
+type noResetTrie struct {
+	trie.StackTrie
+}
+
+func (t *noResetTrie) Reset() {
+}
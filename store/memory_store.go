@@ -0,0 +1,143 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// KeySource is a source that can list its pre-images.
+type KeySource interface {
+	Source
+	Keys() ([]common.Hash, error)
+}
+
+// MemoryStore keeps pre-images in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	*KVStore
+}
+
+var _ Backend = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{KVStore: NewKVStore(memorydb.New())}
+}
+
+// Seed copies all pre-images of the source into the memory store.
+func (m *MemoryStore) Seed(src KeySource) error {
+	return copyPreimages(src, m)
+}
+
+// Flush copies all pre-images of the memory store into the destination store.
+func (m *MemoryStore) Flush(dst Store) error {
+	return copyPreimages(m, dst)
+}
+
+func copyPreimages(src KeySource, dst Store) error {
+	keys, err := src.Keys()
+	if err != nil {
+		return fmt.Errorf("listing pre-images: %w", err)
+	}
+	for _, key := range keys {
+		value, err := src.ReadNode(key)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		if err := dst.StoreNode(key, value); err != nil {
+			return fmt.Errorf("writing %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// preloaded serves the pre-images of a backend from memory. Checkpoints bypass the memory store,
+// so these survive a crash. The pre-images are flushed to the backend before every checkpoint,
+// so a checkpoint never refers to pre-images that only exist in memory.
+type preloaded struct {
+	*MemoryStore
+	backend Backend
+	mu      sync.Mutex
+	// flushed are the pre-images that are known to be in the backend
+	flushed map[common.Hash]struct{}
+}
+
+// Preload seeds a memory store with all pre-images of the backend, and serves the pre-images from memory.
+// Closing the returned backend flushes the pre-images to the backend, and closes the backend.
+func Preload(b Backend) (Backend, error) {
+	keys, err := b.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("preloading store: listing pre-images: %w", err)
+	}
+	m := NewMemoryStore()
+	if err := m.Seed(b); err != nil {
+		return nil, fmt.Errorf("preloading store: %w", err)
+	}
+	flushed := make(map[common.Hash]struct{}, len(keys))
+	for _, key := range keys {
+		flushed[key] = struct{}{}
+	}
+	return &preloaded{MemoryStore: m, backend: b, flushed: flushed}, nil
+}
+
+// flush writes the pre-images that are not in the backend yet to the backend.
+func (p *preloaded) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys, err := p.Keys()
+	if err != nil {
+		return fmt.Errorf("listing pre-images: %w", err)
+	}
+	for _, key := range keys {
+		if _, ok := p.flushed[key]; ok {
+			continue
+		}
+		value, err := p.ReadNode(key)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		if err := p.backend.StoreNode(key, value); err != nil {
+			return fmt.Errorf("writing %s: %w", key, err)
+		}
+		p.flushed[key] = struct{}{}
+	}
+	return nil
+}
+
+func (p *preloaded) StoreCheckpoint(id common.Hash, checkpoint []byte) error {
+	if err := p.flush(); err != nil {
+		return fmt.Errorf("flushing preloaded store before checkpoint: %w", err)
+	}
+	return p.backend.StoreCheckpoint(id, checkpoint)
+}
+
+func (p *preloaded) ReadCheckpoint(id common.Hash) ([]byte, error) {
+	return p.backend.ReadCheckpoint(id)
+}
+
+func (p *preloaded) Checkpoints() ([]common.Hash, error) {
+	return p.backend.Checkpoints()
+}
+
+func (p *preloaded) DeleteCheckpoint(id common.Hash) error {
+	return p.backend.DeleteCheckpoint(id)
+}
+
+func (p *preloaded) Delete(key common.Hash) error {
+	if err := p.MemoryStore.Delete(key); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.flushed, key)
+	p.mu.Unlock()
+	return p.backend.Delete(key)
+}
+
+func (p *preloaded) Close() error {
+	if err := p.flush(); err != nil {
+		p.backend.Close()
+		return fmt.Errorf("flushing preloaded store: %w", err)
+	}
+	return p.backend.Close()
+}
@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"math/rand"
+	"op-mordor/store"
+	"sync"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+
+	disk, err := store.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+	seeded := testutils.RandomHash(rng)
+	require.NoError(t, disk.StoreNode(seeded, testutils.RandomData(rng, 100)))
+
+	m := store.NewMemoryStore()
+	require.NoError(t, m.Seed(disk))
+	_, err = m.ReadNode(seeded)
+	require.NoError(t, err)
+
+	nodes := make(map[common.Hash][]byte)
+	for i := 0; i < 16; i++ {
+		nodes[testutils.RandomHash(rng)] = testutils.RandomData(rng, 100)
+	}
+	var wg sync.WaitGroup
+	for key, node := range nodes {
+		wg.Add(1)
+		go func(key common.Hash, node []byte) {
+			defer wg.Done()
+			require.NoError(t, m.StoreNode(key, node))
+		}(key, node)
+	}
+	wg.Wait()
+
+	require.NoError(t, m.Flush(disk))
+	for key, node := range nodes {
+		value, err := disk.ReadNode(key)
+		require.NoError(t, err)
+		require.Equal(t, node, value)
+	}
+	_, err = m.ReadNode(testutils.RandomHash(rng))
+	requireNoDataError(t, err)
+}
+
+func TestPreloadCheckpoint(t *testing.T) {
+	rng := rand.New(rand.NewSource(421))
+	dir := t.TempDir()
+
+	disk, err := store.NewDiskStore(dir)
+	require.NoError(t, err)
+	preloaded, err := store.Preload(disk)
+	require.NoError(t, err)
+	key, node := testutils.RandomHash(rng), testutils.RandomData(rng, 100)
+	require.NoError(t, preloaded.StoreNode(key, node))
+	id := testutils.RandomHash(rng)
+	require.NoError(t, preloaded.StoreCheckpoint(id, []byte("checkpoint")))
+
+	// the pre-images a checkpoint refers to are in the backend before the preloaded store is closed
+	reopened, err := store.NewDiskStore(dir)
+	require.NoError(t, err)
+	value, err := reopened.ReadNode(key)
+	require.NoError(t, err)
+	require.Equal(t, node, value)
+	checkpoint, err := reopened.ReadCheckpoint(id)
+	require.NoError(t, err)
+	require.Equal(t, []byte("checkpoint"), checkpoint)
+	ids, err := preloaded.Checkpoints()
+	require.NoError(t, err)
+	require.Equal(t, []common.Hash{id}, ids)
+	require.NoError(t, preloaded.Close())
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LevelDBScheme selects the LevelDB backend in a store path, as in "leveldb:///tmp/mordor".
+const LevelDBScheme = "leveldb://"
+
+// Backend is a store with everything the commands need of it, as opened by Open.
+type Backend interface {
+	Store
+	Source
+	CheckpointStore
+
+	// Keys returns the keys of all pre-images in the store.
+	Keys() ([]common.Hash, error)
+
+	// Delete removes the pre-image with the given key, if it is stored.
+	Delete(key common.Hash) error
+
+	// Checkpoints returns the ids of all stored checkpoints.
+	Checkpoints() ([]common.Hash, error)
+
+	// DeleteCheckpoint removes the checkpoint with the given id, if it is stored.
+	DeleteCheckpoint(id common.Hash) error
+
+	Close() error
+}
+
+var (
+	_ Backend = (*DiskStore)(nil)
+	_ Backend = (*KVStore)(nil)
+)
+
+// Open opens the store at the path. Paths with the leveldb:// scheme open a LevelDB database,
+// other paths a DiskStore directory that stores new pre-images with the given compression.
+// LevelDB compresses its data itself.
+func Open(path string, compression Compression) (Backend, error) {
+	if dir := strings.TrimPrefix(path, LevelDBScheme); dir != path {
+		return OpenLevelDB(dir)
+	}
+	return NewDiskStoreWithCompression(path, compression)
+}
+
+// OpenReadOnly opens the existing store at the path like Open, for commands that only read the store.
+// Disk stores with an older layout are read without migrating them.
+func OpenReadOnly(path string) (Backend, error) {
+	if dir := strings.TrimPrefix(path, LevelDBScheme); dir != path {
+		return OpenLevelDBReadOnly(dir)
+	}
+	return OpenDiskStoreReadOnly(path)
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// txLocation is the block and index of a stored transaction.
+type txLocation struct {
+	block common.Hash
+	index int
+}
+
+// PreimageAPI serves the pre-images of a source with the JSON-RPC methods the loading oracles use,
+// so a store can stand in for the L1 and L2 nodes.
+// Uncles are not stored, so blocks with uncles cannot be decoded by clients that check the uncle hashes.
+type PreimageAPI struct {
+	src Source
+	txs map[common.Hash]txLocation
+}
+
+// NewPreimageAPI creates the API, and indexes the transactions of all stored blocks to look up receipts by transaction hash.
+func NewPreimageAPI(src KeySource) (*PreimageAPI, error) {
+	keys, err := src.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("listing pre-images: %w", err)
+	}
+	api := &PreimageAPI{src: src, txs: make(map[common.Hash]txLocation)}
+	for _, key := range keys {
+		value, err := src.ReadNode(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", key, err)
+		}
+		if kind, header, err := classifyEntry(value); err != nil || kind != HeaderEntry {
+			continue
+		} else if txs, err := src.ReadTransactions(header.TxHash); err == nil {
+			for i, tx := range txs {
+				api.txs[tx.Hash()] = txLocation{block: key, index: i}
+			}
+		} else if !IsNoDataError(err) {
+			return nil, fmt.Errorf("reading transactions of %s: %w", key, err)
+		}
+	}
+	return api, nil
+}
+
+// NewPreimageRPCServer creates a JSON-RPC server with the PreimageAPI registered in the "eth" and "debug" namespaces.
+func NewPreimageRPCServer(api *PreimageAPI) (*rpc.Server, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", &preimageEthAPI{api}); err != nil {
+		return nil, fmt.Errorf("registering eth api: %w", err)
+	}
+	if err := srv.RegisterName("debug", &preimageDebugAPI{api}); err != nil {
+		return nil, fmt.Errorf("registering debug api: %w", err)
+	}
+	return srv, nil
+}
+
+// Transactions returns the number of indexed transactions.
+func (api *PreimageAPI) Transactions() int {
+	return len(api.txs)
+}
+
+var errNotFound = errors.New("not found")
+
+// DbGet returns the pre-image with the given key, like debug_dbGet of geth.
+func (api *PreimageAPI) DbGet(key string) (hexutil.Bytes, error) {
+	b, err := hexutil.Decode(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(b) != common.HashLength {
+		return nil, errNotFound
+	}
+	value, err := api.src.ReadNode(common.BytesToHash(b))
+	if IsNoDataError(err) {
+		return nil, errNotFound
+	}
+	return value, err
+}
+
+// GetBlockByHash returns the stored block in the JSON-RPC block format, or nil if the header is not stored.
+// Transactions are only included if they are stored.
+func (api *PreimageAPI) GetBlockByHash(hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	header, err := api.src.ReadHeader(hash)
+	if IsNoDataError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	txs, err := api.src.ReadTransactions(header.TxHash)
+	if IsNoDataError(err) {
+		if fullTx {
+			return nil, fmt.Errorf("transactions of block %s are not stored", hash)
+		}
+		txs = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return marshalBlock(header, txs, fullTx)
+}
+
+// GetTransactionReceipt returns the receipt of a transaction of a stored block, or nil if it is not stored.
+func (api *PreimageAPI) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	loc, ok := api.txs[hash]
+	if !ok {
+		return nil, nil
+	}
+	header, err := api.src.ReadHeader(loc.block)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := api.src.ReadTransactions(header.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := api.src.ReadReceipts(header.ReceiptHash)
+	if IsNoDataError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := DeriveReceiptFields(receipts, header, txs); err != nil {
+		return nil, err
+	}
+	return receipts[loc.index], nil
+}
+
+// marshalBlock converts the header and transactions into the JSON-RPC block format.
+func marshalBlock(header *types.Header, txs types.Transactions, fullTx bool) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encoding header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &fields); err != nil {
+		return nil, fmt.Errorf("decoding header fields: %w", err)
+	}
+	fields["uncles"] = []common.Hash{}
+	if txs == nil {
+		return fields, nil
+	}
+	fields["size"] = hexutil.Uint64(types.NewBlockWithHeader(header).WithBody(txs, nil).Size())
+	list := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		if !fullTx {
+			list[i] = tx.Hash()
+			continue
+		}
+		txFields, err := marshalTransaction(tx, header, uint64(i))
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		list[i] = txFields
+	}
+	fields["transactions"] = list
+	return fields, nil
+}
+
+func marshalTransaction(tx *types.Transaction, header *types.Header, index uint64) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	txJSON, err := tx.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding tx: %w", err)
+	}
+	if err := json.Unmarshal(txJSON, &fields); err != nil {
+		return nil, fmt.Errorf("decoding tx fields: %w", err)
+	}
+	// the chain config is unknown, so the sender is only included if the signature is valid for the chain id of the tx
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		fields["from"] = from
+	}
+	fields["blockHash"] = header.Hash()
+	fields["blockNumber"] = (*hexutil.Big)(header.Number)
+	fields["transactionIndex"] = hexutil.Uint64(index)
+	return fields, nil
+}
+
+// preimageEthAPI exposes the block and receipt methods of the PreimageAPI.
+type preimageEthAPI struct {
+	api *PreimageAPI
+}
+
+func (e *preimageEthAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	return e.api.GetBlockByHash(hash, fullTx)
+}
+
+func (e *preimageEthAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	return e.api.GetTransactionReceipt(hash)
+}
+
+// preimageDebugAPI exposes the pre-images of the PreimageAPI.
+type preimageDebugAPI struct {
+	api *PreimageAPI
+}
+
+func (d *preimageDebugAPI) DbGet(ctx context.Context, key string) (hexutil.Bytes, error) {
+	return d.api.DbGet(key)
+}
@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"context"
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreimageServer(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	block, receipts := testutils.RandomBlock(rng, 4)
+	node := testutils.RandomData(rng, 100)
+
+	s := store.NewMemoryStore()
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(block))
+	require.NoError(t, s.StoreReceipts(receipts))
+	require.NoError(t, s.StoreNode(crypto.Keccak256Hash(node), node))
+
+	api, err := store.NewPreimageAPI(s)
+	require.NoError(t, err)
+	require.Equal(t, len(block.Transactions()), api.Transactions())
+	srv, err := store.NewPreimageRPCServer(api)
+	require.NoError(t, err)
+	defer srv.Stop()
+	rpcClient := rpc.DialInProc(srv)
+	defer rpcClient.Close()
+	client := ethclient.NewClient(rpcClient)
+	ctx := context.Background()
+
+	t.Run("debug_dbGet", func(t *testing.T) {
+		var value hexutil.Bytes
+		require.NoError(t, rpcClient.CallContext(ctx, &value, "debug_dbGet", crypto.Keccak256Hash(node).Hex()))
+		require.Equal(t, hexutil.Bytes(node), value)
+
+		require.Error(t, rpcClient.CallContext(ctx, &value, "debug_dbGet", testutils.RandomHash(rng).Hex()))
+	})
+
+	t.Run("HeaderByHash", func(t *testing.T) {
+		header, err := client.HeaderByHash(ctx, block.Hash())
+		require.NoError(t, err)
+		require.Equal(t, block.Hash(), header.Hash())
+
+		_, err = client.HeaderByHash(ctx, testutils.RandomHash(rng))
+		require.Error(t, err)
+	})
+
+	t.Run("BlockByHash", func(t *testing.T) {
+		got, err := client.BlockByHash(ctx, block.Hash())
+		require.NoError(t, err)
+		require.Equal(t, block.Hash(), got.Hash())
+		require.Len(t, got.Transactions(), len(block.Transactions()))
+		for i, tx := range got.Transactions() {
+			require.Equal(t, block.Transactions()[i].Hash(), tx.Hash())
+		}
+	})
+
+	t.Run("TransactionReceipt", func(t *testing.T) {
+		for i, tx := range block.Transactions() {
+			r, err := client.TransactionReceipt(ctx, tx.Hash())
+			require.NoError(t, err)
+			require.Equal(t, receipts[i].TxHash, r.TxHash)
+			require.Equal(t, block.Hash(), r.BlockHash)
+			require.Equal(t, receipts[i].Logs, r.Logs)
+		}
+
+		_, err := client.TransactionReceipt(ctx, testutils.RandomHash(rng))
+		require.Error(t, err)
+	})
+}
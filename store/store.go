@@ -20,7 +20,8 @@ type Source interface {
 
 	ReadTransactions(txRoot common.Hash) (types.Transactions, error)
 
-	ReadReceipts(hash common.Hash) (types.Receipts, error)
+	// ReadReceipts reads the receipts of the receipts trie with the given root.
+	ReadReceipts(receiptRoot common.Hash) (types.Receipts, error)
 
 	ReadNode(nodeHash common.Hash) (node []byte, err error)
 }
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// VerifyOptions configures the checks of Verify.
+type VerifyOptions struct {
+	// CompleteLists requires the transactions and receipts tries of every stored header to be stored completely.
+	// Without it, only tries that are stored partially are reported.
+	CompleteLists bool
+}
+
+// EntryKind is the kind of a stored pre-image, as classified by Verify.
+type EntryKind string
+
+const (
+	HeaderEntry EntryKind = "header"
+	NodeEntry   EntryKind = "node"
+	// CodeEntry is any value that is not RLP-encoded, like contract code.
+	CodeEntry EntryKind = "code"
+)
+
+// EntryIssue is a stored pre-image that is corrupt.
+type EntryIssue struct {
+	Key    common.Hash
+	Reason string
+}
+
+// ListIssue is a transactions or receipts trie of a stored header that is missing nodes.
+type ListIssue struct {
+	Header  common.Hash
+	List    string
+	Root    common.Hash
+	Missing []common.Hash
+}
+
+// VerifyReport is the outcome of Verify.
+type VerifyReport struct {
+	Entries map[EntryKind]int
+
+	// Mismatches are entries whose keccak256 hash does not match their key, like partially written files.
+	Mismatches []EntryIssue
+	// Undecodable are entries that are RLP lists, but neither a header nor a trie node, or that cannot be read at all.
+	Undecodable []EntryIssue
+	// Orphans are trie nodes that are not reachable from the state, transactions or receipts root of any stored header.
+	Orphans []common.Hash
+	// IncompleteLists are the transactions and receipts tries with missing nodes.
+	IncompleteLists []ListIssue
+}
+
+// OK returns whether the store has no corrupt entries and no incomplete lists. Orphans are not corruption.
+func (r *VerifyReport) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.Undecodable) == 0 && len(r.IncompleteLists) == 0
+}
+
+// Verify checks every pre-image of the source: the key must be the keccak256 hash of the value, and the value
+// must decode as what it looks like. It then walks the tries of all stored headers to find orphaned trie nodes,
+// and tries with missing nodes.
+func Verify(src KeySource, opts VerifyOptions) (*VerifyReport, error) {
+	keys, err := src.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("listing pre-images: %w", err)
+	}
+	report := &VerifyReport{Entries: make(map[EntryKind]int)}
+	kinds := make(map[common.Hash]EntryKind, len(keys))
+	var headers []*types.Header
+	for _, key := range keys {
+		value, err := src.ReadNode(key)
+		if err != nil {
+			report.Undecodable = append(report.Undecodable, EntryIssue{Key: key, Reason: fmt.Sprintf("unreadable: %v", err)})
+			continue
+		}
+		if got := crypto.Keccak256Hash(value); got != key {
+			report.Mismatches = append(report.Mismatches, EntryIssue{Key: key, Reason: fmt.Sprintf("content hashes to %s", got)})
+			continue
+		}
+		kind, header, err := classifyEntry(value)
+		if err != nil {
+			report.Undecodable = append(report.Undecodable, EntryIssue{Key: key, Reason: err.Error()})
+			continue
+		}
+		kinds[key] = kind
+		report.Entries[kind]++
+		if header != nil {
+			headers = append(headers, header)
+		}
+	}
+
+	w := newTrieWalker(src)
+	for _, h := range headers {
+		w.walk(h.Root, StatePreimage)
+		for _, list := range []struct {
+			kind PreimageKind
+			root common.Hash
+		}{{TransactionsPreimage, h.TxHash}, {ReceiptsPreimage, h.ReceiptHash}} {
+			if list.root == types.EmptyRootHash {
+				continue
+			}
+			_, stored := kinds[list.root]
+			missing := w.walk(list.root, list.kind)
+			if len(missing) > 0 && (stored || opts.CompleteLists) {
+				report.IncompleteLists = append(report.IncompleteLists, ListIssue{Header: h.Hash(), List: string(list.kind), Root: list.root, Missing: missing})
+			}
+		}
+	}
+	for _, key := range keys {
+		if _, ok := w.reached[key]; !ok && kinds[key] == NodeEntry {
+			report.Orphans = append(report.Orphans, key)
+		}
+	}
+	return report, nil
+}
+
+// classifyEntry classifies a value whose hash matches its key, and decodes it if it is a header.
+func classifyEntry(value []byte) (EntryKind, *types.Header, error) {
+	kind, _, _, err := rlp.Split(value)
+	if err != nil || kind != rlp.List {
+		return CodeEntry, nil, nil
+	}
+	var header types.Header
+	if err := rlp.DecodeBytes(value, &header); err == nil {
+		return HeaderEntry, &header, nil
+	}
+	if err := nodeRefs(value, func([]byte) error { return nil }, func(common.Hash) {}); err != nil {
+		return "", nil, fmt.Errorf("neither a header nor a trie node: %w", err)
+	}
+	return NodeEntry, nil, nil
+}
+
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// trieWalker walks tries through the nodes of a source, and tracks the kind of the reached pre-images.
+type trieWalker struct {
+	src     Source
+	reached map[common.Hash]PreimageKind
+}
+
+func newTrieWalker(src Source) *trieWalker {
+	return &trieWalker{src: src, reached: make(map[common.Hash]PreimageKind)}
+}
+
+// walk marks all stored nodes of the trie with the given root as reached, and returns the missing nodes.
+// Nodes of the state trie are followed into the storage tries and code of the accounts.
+// State is expected to be stored partially, so missing state nodes are not returned.
+func (w *trieWalker) walk(root common.Hash, kind PreimageKind) []common.Hash {
+	type item struct {
+		hash    common.Hash
+		storage bool
+	}
+	var missing []common.Hash
+	stack := []item{{hash: root}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := w.reached[it.hash]; ok {
+			continue
+		}
+		node, err := w.src.ReadNode(it.hash)
+		if err != nil {
+			if kind != StatePreimage {
+				missing = append(missing, it.hash)
+			}
+			continue
+		}
+		w.reached[it.hash] = kind
+		onLeaf := func(value []byte) error {
+			if kind != StatePreimage || it.storage {
+				return nil
+			}
+			var acc types.StateAccount
+			if err := rlp.DecodeBytes(value, &acc); err != nil {
+				return fmt.Errorf("invalid account: %w", err)
+			}
+			if acc.Root != types.EmptyRootHash {
+				stack = append(stack, item{hash: acc.Root, storage: true})
+			}
+			if codeHash := common.BytesToHash(acc.CodeHash); codeHash != emptyCodeHash {
+				w.reached[codeHash] = CodePreimage
+			}
+			return nil
+		}
+		onRef := func(child common.Hash) {
+			stack = append(stack, item{hash: child, storage: it.storage})
+		}
+		// undecodable nodes are reported by the classification of the entries
+		_ = nodeRefs(node, onLeaf, onRef)
+	}
+	return missing
+}
+
+// nodeRefs decodes a trie node, and calls onLeaf with the values of the leaves and onRef with the hashes of the
+// children. Children that are small enough to be embedded in the node are decoded recursively.
+func nodeRefs(node []byte, onLeaf func(value []byte) error, onRef func(common.Hash)) error {
+	elems, _, err := rlp.SplitList(node)
+	if err != nil {
+		return err
+	}
+	n, err := rlp.CountValues(elems)
+	if err != nil {
+		return err
+	}
+	switch n {
+	case 2:
+		key, rest, err := rlp.SplitString(elems)
+		if err != nil {
+			return fmt.Errorf("invalid short node key: %w", err)
+		}
+		// in the compact encoding of the key, the first nibble has the leaf flag at 0x2
+		if len(key) > 0 && key[0]&0x20 != 0 {
+			value, _, err := rlp.SplitString(rest)
+			if err != nil {
+				return fmt.Errorf("invalid leaf value: %w", err)
+			}
+			return onLeaf(value)
+		}
+		_, err = childRefs(rest, onLeaf, onRef)
+		return err
+	case 17:
+		rest := elems
+		for i := 0; i < 16; i++ {
+			if rest, err = childRefs(rest, onLeaf, onRef); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.New("invalid number of list elements")
+	}
+}
+
+func childRefs(buf []byte, onLeaf func(value []byte) error, onRef func(common.Hash)) ([]byte, error) {
+	kind, content, rest, err := rlp.Split(buf)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case kind == rlp.List:
+		return rest, nodeRefs(buf[:len(buf)-len(rest)], onLeaf, onRef)
+	case len(content) == common.HashLength:
+		onRef(common.BytesToHash(content))
+	case len(content) != 0:
+		return nil, fmt.Errorf("invalid child reference of %d bytes", len(content))
+	}
+	return rest, nil
+}
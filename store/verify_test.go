@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	db := memorydb.New()
+	s := store.NewKVStore(db)
+
+	block, _ := testutils.RandomBlock(rng, 16)
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(block))
+
+	report, err := store.Verify(s, store.VerifyOptions{})
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Equal(t, 1, report.Entries[store.HeaderEntry])
+	require.Empty(t, report.Orphans)
+
+	// receipts of the block were never stored
+	report, err = store.Verify(s, store.VerifyOptions{CompleteLists: true})
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Len(t, report.IncompleteLists, 1)
+	require.Equal(t, "receipts", report.IncompleteLists[0].List)
+
+	orphan, err := rlp.EncodeToBytes([][]byte{{0x20}, testutils.RandomData(rng, 40)})
+	require.NoError(t, err)
+	orphanKey := crypto.Keccak256Hash(orphan)
+	require.NoError(t, s.StoreNode(orphanKey, orphan))
+	corruptKey := testutils.RandomHash(rng)
+	require.NoError(t, s.StoreNode(corruptKey, []byte{1, 2, 3}))
+	undecodable := []byte{0xc3, 0x01, 0x02, 0x03}
+	require.NoError(t, s.StoreNode(crypto.Keccak256Hash(undecodable), undecodable))
+
+	report, err = store.Verify(s, store.VerifyOptions{})
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Equal(t, []common.Hash{orphanKey}, report.Orphans)
+	require.Len(t, report.Mismatches, 1)
+	require.Equal(t, corruptKey, report.Mismatches[0].Key)
+	require.Len(t, report.Undecodable, 1)
+	require.Equal(t, crypto.Keccak256Hash(undecodable), report.Undecodable[0].Key)
+
+	// a partially stored transactions trie is incomplete, even without requiring complete lists
+	require.NoError(t, db.Delete(corruptKey[:]))
+	require.NoError(t, db.Delete(crypto.Keccak256(undecodable)))
+	require.NoError(t, db.Delete(orphanKey[:]))
+	var txNode common.Hash
+	for _, key := range mustKeys(t, s) {
+		if key != block.Hash() && key != block.TxHash() {
+			txNode = key
+		}
+	}
+	require.NoError(t, db.Delete(txNode[:]))
+	report, err = store.Verify(s, store.VerifyOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.IncompleteLists, 1)
+	require.Equal(t, "transactions", report.IncompleteLists[0].List)
+	require.Equal(t, []common.Hash{txNode}, report.IncompleteLists[0].Missing)
+}
+
+func mustKeys(t *testing.T, s store.KeySource) []common.Hash {
+	keys, err := s.Keys()
+	require.NoError(t, err)
+	return keys
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// WitnessKind is the kind of pre-image a key of a witness refers to.
+type WitnessKind string
+
+const (
+	HeaderWitness       WitnessKind = "headers"
+	TransactionsWitness WitnessKind = "transactions"
+	ReceiptsWitness     WitnessKind = "receipts"
+	NodeWitness         WitnessKind = "nodes"
+)
+
+// WitnessKinds lists the witness kinds in reporting order.
+var WitnessKinds = []WitnessKind{HeaderWitness, TransactionsWitness, ReceiptsWitness, NodeWitness}
+
+// Witness tracks the keys a run stores and reads, to export exactly the pre-images the run used.
+// Transactions and receipts are tracked as the keys of their trie nodes, all other pre-images by their hash.
+type Witness struct {
+	mu   sync.Mutex
+	keys map[common.Hash]WitnessKind
+}
+
+func NewWitness() *Witness {
+	return &Witness{keys: make(map[common.Hash]WitnessKind)}
+}
+
+func (w *Witness) add(key common.Hash, kind WitnessKind) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[key]; !ok {
+		w.keys[key] = kind
+	}
+}
+
+// Len returns the number of tracked keys.
+func (w *Witness) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.keys)
+}
+
+// Store wraps the store to track the keys stored in it.
+func (w *Witness) Store(s Store) Store {
+	return witnessStore{Store: s, w: w}
+}
+
+// Source wraps the source to track the keys read from it.
+func (w *Witness) Source(s Source) Source {
+	return witnessSource{Source: s, w: w}
+}
+
+// PreimageStats is the number of pre-images of a kind, and their total size in bytes.
+type PreimageStats struct {
+	Count uint64
+	Bytes uint64
+}
+
+// Export copies the raw pre-images of the tracked keys from the source to the destination store,
+// and returns the number of pre-images and bytes per kind.
+func (w *Witness) Export(src Source, dst Store) (map[WitnessKind]PreimageStats, error) {
+	w.mu.Lock()
+	keys := make([]common.Hash, 0, len(w.keys))
+	for key := range w.keys {
+		keys = append(keys, key)
+	}
+	kinds := make(map[common.Hash]WitnessKind, len(w.keys))
+	for key, kind := range w.keys {
+		kinds[key] = kind
+	}
+	w.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+
+	stats := make(map[WitnessKind]PreimageStats)
+	for _, key := range keys {
+		value, err := src.ReadNode(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s %s: %w", kinds[key], key, err)
+		}
+		if err := dst.StoreNode(key, value); err != nil {
+			return nil, fmt.Errorf("writing %s %s: %w", kinds[key], key, err)
+		}
+		s := stats[kinds[key]]
+		s.Count++
+		s.Bytes += uint64(len(value))
+		stats[kinds[key]] = s
+	}
+	return stats, nil
+}
+
+// addTrie tracks the trie nodes of the list as the given kind.
+func (w *Witness) addTrie(list types.DerivableList, kind WitnessKind) error {
+	hasher := &noResetTrie{*trie.NewStackTrie(witnessWriter{w: w, kind: kind})}
+	types.DeriveSha(list, hasher)
+	if _, err := hasher.Commit(); err != nil {
+		return fmt.Errorf("tracking %s trie: %w", kind, err)
+	}
+	return nil
+}
+
+type witnessWriter struct {
+	w    *Witness
+	kind WitnessKind
+}
+
+func (ww witnessWriter) Put(key []byte, value []byte) error {
+	ww.w.add(common.BytesToHash(key), ww.kind)
+	return nil
+}
+
+func (ww witnessWriter) Delete(key []byte) error {
+	return nil
+}
+
+type witnessStore struct {
+	Store
+	w *Witness
+}
+
+func (s witnessStore) StoreHeader(hash common.Hash, header *types.Header) error {
+	s.w.add(hash, HeaderWitness)
+	return s.Store.StoreHeader(hash, header)
+}
+
+func (s witnessStore) StoreTransactions(txRoot common.Hash, transactions types.Transactions) error {
+	if err := s.Store.StoreTransactions(txRoot, transactions); err != nil {
+		return err
+	}
+	return s.w.addTrie(transactions, TransactionsWitness)
+}
+
+func (s witnessStore) StoreReceipts(receipts types.Receipts) error {
+	if err := s.Store.StoreReceipts(receipts); err != nil {
+		return err
+	}
+	return s.w.addTrie(receipts, ReceiptsWitness)
+}
+
+func (s witnessStore) StoreNode(nodeHash common.Hash, node []byte) error {
+	s.w.add(nodeHash, NodeWitness)
+	return s.Store.StoreNode(nodeHash, node)
+}
+
+type witnessSource struct {
+	Source
+	w *Witness
+}
+
+func (s witnessSource) ReadHeader(hash common.Hash) (*types.Header, error) {
+	h, err := s.Source.ReadHeader(hash)
+	if err == nil {
+		s.w.add(hash, HeaderWitness)
+	}
+	return h, err
+}
+
+func (s witnessSource) ReadTransactions(txRoot common.Hash) (types.Transactions, error) {
+	txs, err := s.Source.ReadTransactions(txRoot)
+	if err != nil {
+		return nil, err
+	}
+	return txs, s.w.addTrie(txs, TransactionsWitness)
+}
+
+func (s witnessSource) ReadReceipts(receiptRoot common.Hash) (types.Receipts, error) {
+	receipts, err := s.Source.ReadReceipts(receiptRoot)
+	if err != nil {
+		return nil, err
+	}
+	return receipts, s.w.addTrie(receipts, ReceiptsWitness)
+}
+
+func (s witnessSource) ReadNode(nodeHash common.Hash) ([]byte, error) {
+	node, err := s.Source.ReadNode(nodeHash)
+	if err == nil {
+		s.w.add(nodeHash, NodeWitness)
+	}
+	return node, err
+}
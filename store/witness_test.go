@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"math/rand"
+	"op-mordor/store"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWitness(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+
+	src := store.NewMemoryStore()
+	unused := testutils.RandomHash(rng)
+	require.NoError(t, src.StoreNode(unused, testutils.RandomData(rng, 100)))
+	read := testutils.RandomHash(rng)
+	readNode := testutils.RandomData(rng, 200)
+	require.NoError(t, src.StoreNode(read, readNode))
+
+	w := store.NewWitness()
+	block, _ := testutils.RandomBlock(rng, 16)
+	require.NoError(t, store.BlockStore{Store: w.Store(src)}.StoreBlock(block))
+	_, err := w.Source(src).ReadNode(read)
+	require.NoError(t, err)
+
+	dst := store.NewMemoryStore()
+	stats, err := w.Export(src, dst)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stats[store.HeaderWitness].Count)
+	require.NotZero(t, stats[store.TransactionsWitness].Count)
+	require.Equal(t, store.PreimageStats{Count: 1, Bytes: 200}, stats[store.NodeWitness])
+
+	exported, err := store.BlockSource{Source: dst}.ReadBlock(block.Hash())
+	require.NoError(t, err)
+	require.Equal(t, block.Hash(), exported.Hash())
+	require.Len(t, exported.Transactions(), len(block.Transactions()))
+	node, err := dst.ReadNode(read)
+	require.NoError(t, err)
+	require.Equal(t, readNode, node)
+	_, err = dst.ReadNode(unused)
+	requireNoDataError(t, err)
+}
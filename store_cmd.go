@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"op-mordor/l2"
+	"op-mordor/oracle"
+	"op-mordor/store"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/urfave/cli"
+)
+
+var storeCommands = []cli.Command{
+	{
+		Name:      "get",
+		Usage:     "Print the raw pre-image stored under a key as hex",
+		ArgsUsage: "<key>",
+		Flags:     []cli.Flag{storeFlag, logLevelFlag, logFormatFlag},
+		Action:    storeGetCmd,
+	},
+	{
+		Name:      "pack",
+		Usage:     "Pack all pre-images of the store into a single-file bundle",
+		ArgsUsage: "<bundle>",
+		Flags:     []cli.Flag{storeFlag, logLevelFlag, logFormatFlag, bundleCompressionFlag},
+		Action:    storePackCmd,
+	},
+	{
+		Name:      "unpack",
+		Usage:     "Unpack all pre-images of a bundle into the store",
+		ArgsUsage: "<bundle>",
+		Flags:     []cli.Flag{storeFlag, storeCompressionFlag, logLevelFlag, logFormatFlag},
+		Action:    storeUnpackCmd,
+	},
+	{
+		Name:   "verify",
+		Usage:  "Check that every pre-image matches its key and decodes, and report orphaned trie nodes and incomplete tries",
+		Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag, completeListsFlag},
+		Action: storeVerifyCmd,
+	},
+	{
+		Name:   "stats",
+		Usage:  "Print the number and size of the pre-images per kind, and the block ranges of the stored headers",
+		Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag},
+		Action: storeStatsCmd,
+	},
+	{
+		Name:   "ls",
+		Usage:  "List the pre-images of the store with their kind and size",
+		Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag, kindFlag},
+		Action: storeLsCmd,
+	},
+	{
+		Name:   "migrate",
+		Usage:  "Migrate a disk store with an older layout to the current layout. Commands that write to the store migrate it too",
+		Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag},
+		Action: storeMigrateCmd,
+	},
+	{
+		Name: "gc",
+		Usage: "Remove the pre-images that are not reachable from the given roots, or from any stored header without roots. " +
+			"The L2 heads of stored checkpoints are roots too. " +
+			"With roots, headers below the --before.l1 and --before.l2 block numbers are removed along with everything only they reach",
+		Flags:  []cli.Flag{storeFlag, logLevelFlag, logFormatFlag, gcRootsFlag, gcAccessLogFlag, gcBeforeL1Flag, gcBeforeL2Flag, gcDryRunFlag},
+		Action: storeGcCmd,
+	},
+}
+
+func storeGetCmd(c *cli.Context) error {
+	cfg, _, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 1, 1); err != nil {
+		return err
+	}
+	key, err := parseHash("key", c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	dstore, err := cfg.openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	value, err := dstore.ReadNode(key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hexutil.Encode(value))
+	return nil
+}
+
+func storePackCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 1, 1); err != nil {
+		return err
+	}
+	compression, err := store.ParseCompression(c.String(bundleCompressionFlag.Name))
+	if err != nil {
+		return err
+	}
+	dstore, err := cfg.openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	keys, err := dstore.Keys()
+	if err != nil {
+		return err
+	}
+	path := c.Args().Get(0)
+	w, err := store.NewBundleWriter(path, compression)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, err := dstore.ReadNode(key)
+		if err != nil {
+			w.Abort()
+			return fmt.Errorf("reading %s: %w", key, err)
+		}
+		if err := w.StoreNode(key, value); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	logger.Info("Packed store", "store", cfg.storePath, "bundle", path, "keys", len(keys))
+	return nil
+}
+
+func storeUnpackCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 1, 1); err != nil {
+		return err
+	}
+	path := c.Args().Get(0)
+	bundle, err := store.OpenBundle(path)
+	if err != nil {
+		return err
+	}
+	defer bundle.Close()
+	dstore, err := cfg.openStore()
+	if err != nil {
+		return err
+	}
+	keys := bundle.Keys()
+	for _, key := range keys {
+		value, err := bundle.ReadNode(key)
+		if err != nil {
+			return err
+		}
+		if err := dstore.StoreNode(key, value); err != nil {
+			return fmt.Errorf("writing %s: %w", key, err)
+		}
+	}
+	logger.Info("Unpacked bundle", "bundle", path, "store", cfg.storePath, "keys", len(keys))
+	return nil
+}
+
+func storeVerifyCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 0, 0); err != nil {
+		return err
+	}
+	dstore, err := cfg.openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	report, err := store.Verify(dstore, store.VerifyOptions{CompleteLists: c.Bool(completeListsFlag.Name)})
+	if err != nil {
+		return err
+	}
+	for _, issue := range report.Mismatches {
+		fmt.Printf("mismatch %s: %s\n", issue.Key, issue.Reason)
+	}
+	for _, issue := range report.Undecodable {
+		fmt.Printf("undecodable %s: %s\n", issue.Key, issue.Reason)
+	}
+	for _, issue := range report.IncompleteLists {
+		fmt.Printf("incomplete %s of header %s: root %s is missing %d nodes\n", issue.List, issue.Header, issue.Root, len(issue.Missing))
+	}
+	for _, key := range report.Orphans {
+		fmt.Printf("orphan %s\n", key)
+	}
+	logger.Info("Verified store", "store", cfg.storePath,
+		"headers", report.Entries[store.HeaderEntry], "nodes", report.Entries[store.NodeEntry], "code", report.Entries[store.CodeEntry],
+		"mismatches", len(report.Mismatches), "undecodable", len(report.Undecodable),
+		"incompleteLists", len(report.IncompleteLists), "orphans", len(report.Orphans))
+	if !report.OK() {
+		return errors.New("store is corrupt")
+	}
+	return nil
+}
+
+func openInventory(c *cli.Context) (*store.Inventory, error) {
+	cfg, _, err := setup(c)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 0, 0); err != nil {
+		return nil, err
+	}
+	dstore, err := cfg.openStoreReadOnly()
+	if err != nil {
+		return nil, err
+	}
+	return store.NewInventory(dstore)
+}
+
+func storeStatsCmd(c *cli.Context) error {
+	inv, err := openInventory(c)
+	if err != nil {
+		return err
+	}
+	var total store.PreimageStats
+	for _, kind := range store.PreimageKinds {
+		s := inv.Stats[kind]
+		total.Count += s.Count
+		total.Bytes += s.Bytes
+		fmt.Printf("%-13s %10d %14d bytes\n", kind, s.Count, s.Bytes)
+	}
+	fmt.Printf("%-13s %10d %14d bytes\n", "total", total.Count, total.Bytes)
+	for _, chain := range []string{"l1", "l2"} {
+		var lo, hi uint64
+		found := false
+		for _, r := range inv.Ranges {
+			if r.Chain != chain {
+				continue
+			}
+			if !found || r.First < lo {
+				lo = r.First
+			}
+			if !found || r.Last > hi {
+				hi = r.Last
+			}
+			found = true
+			fmt.Printf("%s blocks %d-%d\n", chain, r.First, r.Last)
+		}
+		if found {
+			fmt.Printf("%s lowest %d highest %d\n", chain, lo, hi)
+		}
+	}
+	return nil
+}
+
+func storeLsCmd(c *cli.Context) error {
+	inv, err := openInventory(c)
+	if err != nil {
+		return err
+	}
+	kind := store.PreimageKind(c.String(kindFlag.Name))
+	for _, e := range inv.Entries {
+		if kind != "" && e.Kind != kind {
+			continue
+		}
+		if e.Header != nil {
+			fmt.Printf("%s %s %d %d\n", e.Key, e.Kind, e.Size, e.Header.Number)
+		} else {
+			fmt.Printf("%s %s %d\n", e.Key, e.Kind, e.Size)
+		}
+	}
+	return nil
+}
+
+func storeMigrateCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 0, 0); err != nil {
+		return err
+	}
+	// opening the store for writing migrates it
+	if _, err := cfg.openStore(); err != nil {
+		return err
+	}
+	logger.Info("Migrated store", "store", cfg.storePath)
+	return nil
+}
+
+func storeGcCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 0, 0); err != nil {
+		return err
+	}
+	beforeL1, beforeL2 := c.Uint64(gcBeforeL1Flag.Name), c.Uint64(gcBeforeL2Flag.Name)
+	rootsPath, accessLogs := c.String(gcRootsFlag.Name), c.StringSlice(gcAccessLogFlag.Name)
+	keepAll := rootsPath == "" && len(accessLogs) == 0
+	if keepAll && (beforeL1 > 0 || beforeL2 > 0) {
+		// whether a stored header is an L1 or L2 header is only known from the roots it is reached from
+		return fmt.Errorf("--%s and --%s need --%s or --%s", gcBeforeL1Flag.Name, gcBeforeL2Flag.Name, gcRootsFlag.Name, gcAccessLogFlag.Name)
+	}
+	dstore, err := cfg.openStore()
+	if err != nil {
+		return err
+	}
+	collector := store.NewCollector(dstore, beforeL1, beforeL2)
+	stale, err := keepCheckpoints(collector, dstore, beforeL2)
+	if err != nil {
+		return fmt.Errorf("keeping checkpoints: %w", err)
+	}
+	if keepAll {
+		if err := collector.KeepAll(); err != nil {
+			return err
+		}
+	}
+	if rootsPath != "" {
+		if err := keepRoots(collector, rootsPath); err != nil {
+			return fmt.Errorf("keeping roots: %w", err)
+		}
+	}
+	for _, path := range accessLogs {
+		accesses, err := readAccessLog(path)
+		if err != nil {
+			return err
+		}
+		if err := keepAccesses(collector, accesses); err != nil {
+			return fmt.Errorf("keeping access log %s: %w", path, err)
+		}
+	}
+	garbage, err := collector.Garbage()
+	if err != nil {
+		return err
+	}
+	if c.Bool(gcDryRunFlag.Name) {
+		for _, key := range garbage {
+			fmt.Println(key)
+		}
+		logger.Info("Found garbage", "store", cfg.storePath, "keys", len(garbage), "checkpoints", len(stale))
+		return nil
+	}
+	// the checkpoints go first, so an interrupted gc never leaves a checkpoint without its state
+	for _, id := range stale {
+		if err := dstore.DeleteCheckpoint(id); err != nil {
+			return err
+		}
+	}
+	for _, key := range garbage {
+		if err := dstore.Delete(key); err != nil {
+			return err
+		}
+	}
+	logger.Info("Removed garbage", "store", cfg.storePath, "keys", len(garbage), "checkpoints", len(stale))
+	return nil
+}
+
+// keepCheckpoints keeps the chains of the L2 heads of the stored checkpoints, so the runs can resume from these.
+// It returns the checkpoints whose L2 head is evicted, which cannot be resumed anymore.
+func keepCheckpoints(collector *store.Collector, dstore store.Backend, beforeL2 uint64) ([]common.Hash, error) {
+	ids, err := dstore.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	var stale []common.Hash
+	for _, id := range ids {
+		data, err := dstore.ReadCheckpoint(id)
+		if err != nil {
+			return nil, err
+		}
+		var cp l2.Checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("decoding checkpoint %s: %w", id, err)
+		}
+		if cp.L2Head.Number < beforeL2 {
+			stale = append(stale, id)
+			continue
+		}
+		if err := collector.KeepChain(store.L2Chain, cp.L2Head.Hash); err != nil {
+			return nil, err
+		}
+	}
+	return stale, nil
+}
+
+// keepRoots keeps the chains of the L1 head and L2 block pairs of the roots file, one pair per line.
+func keepRoots(collector *store.Collector, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected <l1 head hash> <l2 block hash>", i+1)
+		}
+		for j, chain := range []store.Chain{store.L1Chain, store.L2Chain} {
+			hash, err := parseHash(fmt.Sprintf("line %d %s hash", i+1, chain), fields[j])
+			if err != nil {
+				return err
+			}
+			if err := collector.KeepChain(chain, hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepAccesses keeps exactly the pre-images served for the recorded accesses.
+func keepAccesses(collector *store.Collector, accesses []oracle.Access) error {
+	for _, a := range accesses {
+		var err error
+		switch a.Kind {
+		case oracle.L1HeaderAccess:
+			err = collector.KeepHeader(store.L1Chain, a.Key, false, false)
+		case oracle.L1TransactionsAccess:
+			err = collector.KeepHeader(store.L1Chain, a.Key, true, false)
+		case oracle.L2BlockAccess:
+			err = collector.KeepHeader(store.L2Chain, a.Key, true, false)
+		case oracle.L1ReceiptsAccess:
+			// the L1 oracle loads the transactions of the block along with the receipts
+			err = collector.KeepHeader(store.L1Chain, a.Key, true, true)
+		case oracle.L2NodeAccess:
+			collector.KeepNode(a.Key)
+		default:
+			err = fmt.Errorf("unknown access kind %q", a.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func servePreimagesCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	dstore, err := cfg.openStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	api, err := store.NewPreimageAPI(dstore)
+	if err != nil {
+		return err
+	}
+	srv, err := store.NewPreimageRPCServer(api)
+	if err != nil {
+		return err
+	}
+	logger.Info("Indexed stored transactions", "count", api.Transactions())
+	return serveRPC(logger, srv, "pre-image", c.String(preimageAddrFlag.Name))
+}
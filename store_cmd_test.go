@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"op-mordor/l2"
+	"op-mordor/store"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum-optimism/optimism/op-node/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorePackFailure(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+	_, err := store.NewDiskStore(storePath)
+	require.NoError(t, err)
+	// a record with an unknown compression cannot be read
+	key := common.Hash{0x01}
+	require.NoError(t, os.MkdirAll(filepath.Join(storePath, "01"), 0777))
+	require.NoError(t, os.WriteFile(filepath.Join(storePath, "01", key.Hex()), []byte{0x07}, 0666))
+
+	bundle := filepath.Join(dir, "test.bundle")
+	err = newApp().Run([]string{"op-mordor", "store", "pack", "--store", storePath, "--log.level", "error", bundle})
+	require.ErrorContains(t, err, key.Hex())
+	require.NoFileExists(t, bundle)
+	require.NoFileExists(t, bundle+".tmp")
+}
+
+func TestStoreGcCheckpoints(t *testing.T) {
+	rng := rand.New(rand.NewSource(420))
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store")
+	s, err := store.NewDiskStore(storePath)
+	require.NoError(t, err)
+	block, _ := testutils.RandomBlock(rng, 4)
+	require.NoError(t, store.BlockStore{Store: s}.StoreBlock(block))
+	id := testutils.RandomHash(rng)
+	data, err := json.Marshal(&l2.Checkpoint{L2Head: eth.L2BlockRef{Hash: block.Hash(), Number: block.NumberU64()}})
+	require.NoError(t, err)
+	require.NoError(t, s.StoreCheckpoint(id, data))
+	// an empty access log keeps nothing but the roots of the checkpoints
+	accessLog := filepath.Join(dir, "access.log")
+	require.NoError(t, os.WriteFile(accessLog, nil, 0666))
+	gc := func(flags ...string) error {
+		args := []string{"op-mordor", "store", "gc", "--store", storePath, "--log.level", "error", "--access-log", accessLog}
+		return newApp().Run(append(args, flags...))
+	}
+
+	require.NoError(t, gc())
+	_, err = store.BlockSource{Source: s}.ReadBlock(block.Hash())
+	require.NoError(t, err)
+	_, err = s.ReadCheckpoint(id)
+	require.NoError(t, err)
+
+	// evicting the L2 head makes the checkpoint unresumable
+	require.NoError(t, gc("--before.l2", fmt.Sprint(block.NumberU64()+1)))
+	_, err = s.ReadCheckpoint(id)
+	require.True(t, store.IsNoDataError(err))
+	_, err = s.ReadHeader(block.Hash())
+	require.True(t, store.IsNoDataError(err))
+}
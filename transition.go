@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"op-mordor/oracle"
+	"os"
+
+	"github.com/ethereum-optimism/optimism/op-node/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli"
+)
+
+func transitionCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 2, 2); err != nil {
+		return err
+	}
+	parentHash, err := parseHash("l2 parent hash", c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	_, l2Oracle, err := cfg.setupOracles(logger)
+	if err != nil {
+		return fmt.Errorf("setting up oracles: %w", err)
+	}
+	payload, err := loadPayload(ctx, l2Oracle, c.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("loading payload: %w", err)
+	}
+	l2Engine, err := cfg.setupL2Engine(ctx, logger, parentHash, l2Oracle)
+	if err != nil {
+		return err
+	}
+	out, err := l2Engine.Transition(ctx, payload)
+	if err != nil {
+		return fmt.Errorf("state transition: %w", err)
+	}
+	fmt.Printf("block number: %d\nblock hash: %s\noutput root: %s\n", payload.BlockNumber, payload.BlockHash, out)
+	return nil
+}
+
+// loadPayload reads the payload from a JSON file, or, if the input is a block hash, loads the block with the oracle.
+func loadPayload(ctx context.Context, l2Oracle oracle.L2Oracle, input string) (*eth.ExecutionPayload, error) {
+	var blockHash common.Hash
+	if err := blockHash.UnmarshalText([]byte(input)); err == nil {
+		block, err := l2Oracle.FetchL2Block(ctx, blockHash)
+		if err != nil {
+			return nil, fmt.Errorf("fetching block %s: %w", blockHash, err)
+		}
+		return eth.BlockAsPayload(block)
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading payload file: %w", err)
+	}
+	var payload eth.ExecutionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("decoding payload json: %w", err)
+	}
+	return &payload, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"op-mordor/l2"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli"
+)
+
+func withdrawalCmd(c *cli.Context) error {
+	cfg, logger, err := setup(c)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+	if err := expectArgs(c, 3, 3); err != nil {
+		return err
+	}
+	blockHash, err := parseHash("l2 block hash", c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	// the proof is only valid for the index the output root is proposed at, which the L2 chain does not know
+	l2OutputIndex, ok := new(big.Int).SetString(c.Args().Get(2), 0)
+	if !ok || l2OutputIndex.Sign() < 0 {
+		return fmt.Errorf("bad l2 output index input %q", c.Args().Get(2))
+	}
+
+	ctx := context.Background()
+	withdrawal, err := cfg.loadWithdrawal(ctx, logger, c.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("loading withdrawal: %w", err)
+	}
+	_, l2Oracle, err := cfg.setupOracles(logger)
+	if err != nil {
+		return fmt.Errorf("setting up oracles: %w", err)
+	}
+	l2Engine, err := cfg.setupL2Engine(ctx, logger, blockHash, l2Oracle)
+	if err != nil {
+		return err
+	}
+	proof, err := l2Engine.HeadWithdrawalProof(withdrawal, l2OutputIndex)
+	if err != nil {
+		return fmt.Errorf("proving withdrawal: %w", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(proof)
+}
+
+// loadWithdrawal reads the withdrawal from a JSON file, or, if the input is a transaction hash,
+// parses it from the MessagePassed event of the transaction receipt on the L2 RPC.
+func (cfg *config) loadWithdrawal(ctx context.Context, logger log.Logger, input string) (*l2.Withdrawal, error) {
+	var txHash common.Hash
+	if err := txHash.UnmarshalText([]byte(input)); err == nil {
+		dialCtx, cancel := context.WithTimeout(ctx, cfg.dialTimeout)
+		defer cancel()
+		client, err := cfg.dialL2Reference(dialCtx, logger)
+		if err != nil {
+			return nil, err
+		}
+		receipt, err := client.TransactionReceipt(ctx, txHash)
+		if err != nil {
+			return nil, fmt.Errorf("fetching receipt of tx %s: %w", txHash, err)
+		}
+		return l2.WithdrawalFromReceipt(receipt)
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading withdrawal file: %w", err)
+	}
+	var withdrawal l2.Withdrawal
+	if err := json.Unmarshal(data, &withdrawal); err != nil {
+		return nil, fmt.Errorf("decoding withdrawal json: %w", err)
+	}
+	if withdrawal.Nonce == nil || withdrawal.Value == nil || withdrawal.GasLimit == nil {
+		return nil, fmt.Errorf("withdrawal json is missing nonce, value or gasLimit")
+	}
+	return &withdrawal, nil
+}